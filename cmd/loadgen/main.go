@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/loadgen"
+)
+
+// loadgen sends HTTP load to a running server and reports latency
+// percentiles and the share of 429 responses, e.g. against docker-compose
+//
+//	go run ./cmd/loadgen -rps 500 -duration 30s -ips 10000 -ip-dist zipf
+func main() {
+	url := flag.String("url", "http://localhost:8080/api/test", "URL requested with GET")
+	rps := flag.Int("rps", 100, "target requests per second")
+	duration := flag.Duration("duration", 10*time.Second, "how long to send load")
+	concurrency := flag.Int("concurrency", 50, "maximum requests in flight, requests beyond it are dropped")
+	ips := flag.Int("ips", 1000, "number of distinct client IPs sent in X-Forwarded-For")
+	ipDist := flag.String("ip-dist", loadgen.DistUniform, "distribution of requests across IPs: uniform or zipf")
+	tokens := flag.String("tokens", "token123,premium-token", "tokens sent in the API_KEY header, separated by commas")
+	tokenRatio := flag.Float64("token-ratio", 0, "share of requests sent with a token, between 0 and 1")
+	tokenDist := flag.String("token-dist", loadgen.DistUniform, "distribution of requests across tokens: uniform or zipf")
+	zipfS := flag.Float64("zipf-s", 1.1, "Zipf exponent, greater than 1")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed, fix it to repeat a run")
+	flag.Parse()
+
+	var tokenList []string
+	for _, token := range strings.Split(*tokens, ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokenList = append(tokenList, token)
+		}
+	}
+
+	config := loadgen.Config{
+		URL:         *url,
+		RPS:         *rps,
+		Duration:    *duration,
+		Concurrency: *concurrency,
+		IPs:         *ips,
+		IPDist:      *ipDist,
+		Tokens:      tokenList,
+		TokenRatio:  *tokenRatio,
+		TokenDist:   *tokenDist,
+		ZipfS:       *zipfS,
+		Seed:        *seed,
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Ctrl+C stops the run early and still prints the report
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConns:        *concurrency,
+			MaxIdleConnsPerHost: *concurrency,
+		},
+	}
+
+	log.Printf("Sending %d req/s to %s for %v", *rps, *url, *duration)
+	result, err := loadgen.Run(ctx, config, client)
+	if err != nil {
+		log.Fatalf("Load generation failed: %v", err)
+	}
+	loadgen.WriteReport(os.Stdout, result)
+}
@@ -5,16 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/config"
-	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
-	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/middleware"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/decision"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/health"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/proxy"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/rls"
 	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/usage"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit"
+	"google.golang.org/grpc"
 )
 
 type Response struct {
@@ -25,46 +32,198 @@ type Response struct {
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
+	loadedAt := time.Now()
 
 	// Initialize Redis storage strategy
-	storage, err := strategy.NewRedisStorage(cfg.RedisHost, cfg.RedisPort, cfg.RedisDB)
+	storage, err := ratelimit.NewRedisStorage(redisOptions(cfg))
 	if err != nil {
 		log.Fatalf("Failed to initialize Redis storage: %v", err)
 	}
 	defer storage.Close()
 
-	// Create rate limiter
-	rateLimiter := limiter.NewRateLimiter(
-		storage,
-		cfg.RateLimitIP,
-		cfg.IPBlockDuration,
-	)
+	failureMode, err := ratelimit.ParseFailureMode(cfg.FailureMode)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Chaos testing: degrade Redis on purpose (staging only)
+	var backend ratelimit.Storage = storage
+	if cfg.FaultInjectionEnabled {
+		faults := ratelimit.FaultConfig{
+			Latency:         time.Duration(cfg.FaultLatencyMs) * time.Millisecond,
+			Jitter:          time.Duration(cfg.FaultJitterMs) * time.Millisecond,
+			ErrorRate:       cfg.FaultErrorRate,
+			TimeoutRate:     cfg.FaultTimeoutRate,
+			Timeout:         time.Duration(cfg.FaultTimeoutMs) * time.Millisecond,
+			LostReplyRate:   cfg.FaultLostReplyRate,
+			ExpireErrorRate: cfg.FaultExpireErrorRate,
+			SplitConsume:    cfg.FaultSplitConsume,
+			Seed:            time.Now().UnixNano(),
+		}
+		if err := faults.Validate(); err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		log.Printf("WARNING: injecting storage faults %+v, do not enable in production", faults)
+		backend = ratelimit.NewFaultStorage(storage, faults)
+	}
+
+	// Stop calling Redis while it is down and probe for recovery
+	breaker := ratelimit.NewCircuitBreaker(backend, cfg.CircuitBreakerThreshold, cfg.CircuitBreakerTimeout)
+
+	// Optionally answer blocked clients locally and batch increments
+	var limiterStorage ratelimit.Storage = breaker
+	if cfg.LocalCacheEnabled {
+		limiterStorage = ratelimit.NewCachedStorage(breaker, cfg.LocalCacheBatchSize, cfg.LocalCacheStalenessMs, cfg.LocalCacheMaxKeys)
+	}
+
+	policies, err := ratelimit.ParsePolicies(cfg.Policies)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Create rate limiter (example tokens included)
+	options := []ratelimit.Option{
+		ratelimit.WithLimit(cfg.RateLimitIP),
+		ratelimit.WithBlockDuration(cfg.IPBlockDuration),
+		ratelimit.WithFailureMode(failureMode),
+		ratelimit.WithDecisionTimeout(time.Duration(cfg.LimiterTimeoutMs) * time.Millisecond),
+		ratelimit.WithToken("token123", cfg.RateLimitToken, cfg.TokenBlockDuration),
+		ratelimit.WithToken("premium-token", 100, 60),
+	}
+	for _, policy := range policies {
+		if policy.Shadow {
+			options = append(options, ratelimit.WithShadowPolicy(policy.Name, policy.Limit, policy.BlockDuration))
+			continue
+		}
+		options = append(options, ratelimit.WithPolicy(policy.Name, policy.Limit, policy.BlockDuration))
+	}
+
+	// Stacked quotas per token (e.g. per second and per month), aligned to the billing time zone
+	quotas, err := ratelimit.ParseQuotas(cfg.Quotas)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	quotaLocation, err := time.LoadLocation(cfg.QuotaTimezone)
+	if err != nil {
+		log.Fatalf("Invalid configuration: QUOTA_TIMEZONE: %v", err)
+	}
+	options = append(options, ratelimit.WithQuotaLocation(quotaLocation))
+	for token, windows := range quotas {
+		options = append(options, ratelimit.WithQuotas(token, windows...))
+	}
+	rateLimiter := ratelimit.New(limiterStorage, options...)
 	defer rateLimiter.Close()
 
-	// Configure tokens (example tokens)
-	rateLimiter.ConfigureToken("token123", cfg.RateLimitToken, cfg.TokenBlockDuration)
-	rateLimiter.ConfigureToken("premium-token", 100, 60)
+	// Usage totals per token and hour, aggregated off the request path
+	var usageRecorder *ratelimit.UsageRecorder
+	if cfg.UsageEnabled {
+		if cfg.UsageFlushIntervalMs <= 0 || cfg.UsageRetentionDays <= 0 {
+			log.Fatalf("Invalid configuration: USAGE_FLUSH_INTERVAL_MS and USAGE_RETENTION_DAYS must be positive")
+		}
+		usageRecorder, err = ratelimit.NewUsageRecorder(breaker, time.Duration(cfg.UsageFlushIntervalMs)*time.Millisecond, time.Duration(cfg.UsageRetentionDays)*24*time.Hour)
+		if err != nil {
+			log.Fatalf("Failed to initialize usage accounting: %v", err)
+		}
+		rateLimiter.AddObserver(usageRecorder)
+		if cfg.AdminPort == 0 {
+			log.Printf("Usage accounting is enabled but ADMIN_PORT is not set, GET /usage is not exposed")
+		}
+	}
+	if cfg.DecisionAPIEnabled && cfg.AdminPort == 0 {
+		log.Printf("Decision API is enabled but ADMIN_PORT is not set, /v1/check is not exposed")
+	}
+
+	// Signed webhooks when tokens approach or reach their quotas
+	var notifier *ratelimit.WebhookNotifier
+	if cfg.WebhookURL != "" {
+		thresholds, err := ratelimit.ParseWebhookThresholds(cfg.WebhookThresholds)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		if cfg.WebhookSecret == "" {
+			log.Fatalf("Invalid configuration: WEBHOOK_SECRET is required with WEBHOOK_URL")
+		}
+		notifier = ratelimit.NewWebhookNotifier(breaker, cfg.WebhookURL, cfg.WebhookSecret, thresholds)
+		rateLimiter.AddObserver(notifier)
+	}
+
+	// Request cost: trusted header first, then route rules, otherwise 1
+	costRules, err := ratelimit.ParseCostRules(cfg.CostRules)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cost := ratelimit.RouteCost(costRules, nil)
+	if cfg.CostHeader != "" {
+		cost = ratelimit.HeaderCost(cfg.CostHeader, cost)
+	}
+
+	// Shadow policies compared with the enforced limits, to try a limit before switching to it
+	shadowRules, err := ratelimit.ParseShadowRules(cfg.ShadowRules)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	for _, rule := range shadowRules {
+		if _, exists := rateLimiter.Policy(rule.Policy); !exists {
+			log.Fatalf("Invalid configuration: SHADOW_RULES references unknown policy %q", rule.Policy)
+		}
+	}
+	if len(shadowRules) > 0 {
+		log.Printf("✓ Shadow Policies: %d route rules", len(shadowRules))
+	}
+
+	// In-flight request limits, applied after the rate limit so denied requests do not take slots
+	rateLimited := ratelimit.MiddlewareWithShadow(rateLimiter, cost, shadowRules)
+	limited := rateLimited
+	if cfg.ConcurrencyLimit > 0 || cfg.ConcurrencyRules != "" || cfg.ConcurrencyTokens != "" {
+		concurrencyRules, err := ratelimit.ParseConcurrencyRules(cfg.ConcurrencyRules)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		concurrencyTokens, err := ratelimit.ParseConcurrencyTokens(cfg.ConcurrencyTokens)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		if cfg.ConcurrencyLeaseSeconds <= 0 {
+			log.Fatalf("Invalid configuration: CONCURRENCY_LEASE_SECONDS must be positive")
+		}
+
+		concurrencyLimiter, err := ratelimit.NewConcurrencyLimiter(breaker, cfg.ConcurrencyLimit, time.Duration(cfg.ConcurrencyLeaseSeconds)*time.Second, failureMode)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		defer concurrencyLimiter.Close()
+		concurrencyLimiter.SetDecisionTimeout(time.Duration(cfg.LimiterTimeoutMs) * time.Millisecond)
+		for token, limit := range concurrencyTokens {
+			concurrencyLimiter.ConfigureToken(token, limit)
+		}
+		concurrency := ratelimit.ConcurrencyMiddleware(concurrencyLimiter, concurrencyRules)
+		limited = func(next http.Handler) http.Handler {
+			return rateLimited(concurrency(next))
+		}
+		log.Printf("✓ Concurrency Limit: %d in-flight requests (%d route rules, %d tokens)", cfg.ConcurrencyLimit, len(concurrencyRules), len(concurrencyTokens))
+	}
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 
-	// Health check endpoint (without rate limiting)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	})
-
-	// Protected endpoints with rate limiting middleware
-	rateLimitedMux := http.NewServeMux()
-	rateLimitedMux.HandleFunc("/", handleRequest)
-	rateLimitedMux.HandleFunc("/api/test", handleTestRequest)
+	// Health check endpoints (without rate limiting)
+	checker := health.NewChecker(breaker, breaker, time.Duration(cfg.ReadinessTimeoutMs)*time.Millisecond, failureMode)
+	// Configuration is read once at startup from .env and the environment
+	checker.SetConfig(loadedAt, false)
+	mux.HandleFunc("/health", checker.LivenessHandler())
+	mux.HandleFunc("/livez", checker.LivenessHandler())
 
-	// Apply middleware to protected endpoints
-	rateLimitedHandler := middleware.RateLimiterMiddleware(rateLimiter)(rateLimitedMux)
+	// Readiness details are only exposed on the admin listener when it is enabled
+	mux.HandleFunc("/readyz", checker.ReadinessHandler(cfg.AdminPort == 0))
 
-	// Combine both handlers
-	mux.Handle("/api/", rateLimitedHandler)
+	// Subrequest endpoint for proxies that decide before forwarding
+	if cfg.ForwardAuthEnabled {
+		if cfg.ForwardAuthDenyStatus != http.StatusTooManyRequests && cfg.ForwardAuthDenyStatus != http.StatusForbidden {
+			log.Fatalf("Invalid configuration: FORWARD_AUTH_DENY_STATUS must be 429 or 403")
+		}
+		mux.Handle("/forward-auth", ratelimit.ForwardAuthHandler(rateLimiter, cfg.ForwardAuthDenyStatus))
+		log.Printf("✓ Forward auth: /forward-auth (denied requests get %d)", cfg.ForwardAuthDenyStatus)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -75,6 +234,100 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	if cfg.ProxyRoutes != "" {
+		// Reverse-proxy mode: rate limit and forward everything else to the upstreams
+		routes, err := proxy.ParseRoutes(cfg.ProxyRoutes)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		trustedProxies, err := proxy.ParseTrustedProxies(cfg.ProxyTrustedProxies)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		proxyHandler := proxy.NewHandler(routes, cfg.ProxyTimeoutMs, cfg.ProxyStripPrefix)
+		mux.Handle("/", proxy.TrustForwardedHeaders(trustedProxies)(limited(proxyHandler)))
+
+		// Bodies are streamed, so only the headers are bounded by the server
+		server.ReadTimeout = 0
+		server.WriteTimeout = 0
+		server.ReadHeaderTimeout = 15 * time.Second
+
+		for _, route := range routes {
+			log.Printf("✓ Proxy: %s -> %s", route.Prefix, route.Upstream)
+		}
+	} else {
+		// Protected endpoints with rate limiting middleware
+		rateLimitedMux := http.NewServeMux()
+		rateLimitedMux.HandleFunc("/", handleRequest)
+		rateLimitedMux.HandleFunc("/api/test", handleTestRequest)
+
+		// Apply middleware to protected endpoints
+		rateLimitedHandler := limited(rateLimitedMux)
+
+		// Combine both handlers
+		mux.Handle("/api/", rateLimitedHandler)
+	}
+
+	// Admin server, only reachable on its own port
+	var adminServer *http.Server
+	if cfg.AdminPort != 0 {
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("/livez", checker.LivenessHandler())
+		adminMux.HandleFunc("/readyz", checker.ReadinessHandler(true))
+		adminMux.HandleFunc("/shadow", ratelimit.ShadowStatsHandler(rateLimiter))
+		adminMux.HandleFunc("/storage", ratelimit.StorageFailuresHandler(rateLimiter))
+		if usageRecorder != nil {
+			usage.NewHandler(breaker).Register(adminMux)
+		}
+
+		// Rate limit decisions for internal callers, never exposed to clients
+		if cfg.DecisionAPIEnabled {
+			decision.NewHandler(rateLimiter).Register(adminMux)
+			log.Printf("✓ Decision API: POST /v1/check, POST /v1/check/batch, GET /v1/quota (%d policies)", len(policies))
+		}
+
+		adminServer = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.AdminPort),
+			Handler:      adminMux,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+
+		go func() {
+			log.Printf("✓ Admin server listening on port :%d", cfg.AdminPort)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin server error: %v", err)
+			}
+		}()
+	}
+
+	// Envoy global rate limit service, on its own gRPC port
+	var rlsServer *grpc.Server
+	if cfg.RLSPort != 0 {
+		rules, err := rls.ParseRules(cfg.RLSRules)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		service, err := rls.NewServer(rateLimiter, rules)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.RLSPort))
+		if err != nil {
+			log.Fatalf("RLS listener error: %v", err)
+		}
+		rlsServer = grpc.NewServer()
+		service.Register(rlsServer)
+
+		go func() {
+			log.Printf("✓ Envoy RLS listening on port :%d (%d rules)", cfg.RLSPort, len(rules))
+			if err := rlsServer.Serve(listener); err != nil {
+				log.Fatalf("RLS server error: %v", err)
+			}
+		}()
+	}
+
 	// Start server in goroutine
 	go func() {
 		log.Printf("✓ Rate Limiter started successfully!")
@@ -83,7 +336,26 @@ func main() {
 		log.Printf("✓ Block Duration (IP): %d seconds", cfg.IPBlockDuration)
 		log.Printf("✓ Rate Limit (Token): %d requests/sec", cfg.RateLimitToken)
 		log.Printf("✓ Block Duration (Token): %d seconds", cfg.TokenBlockDuration)
-		log.Printf("✓ Redis: %s:%d", cfg.RedisHost, cfg.RedisPort)
+		if len(cfg.RedisAddrs) > 0 && cfg.RedisMode != strategy.RedisModeStandalone {
+			log.Printf("✓ Redis: %s mode, nodes %s", cfg.RedisMode, strings.Join(cfg.RedisAddrs, ","))
+		} else if cfg.RedisURL != "" {
+			log.Printf("✓ Redis: URL configured (TLS: %t)", cfg.RedisTLS || strings.HasPrefix(cfg.RedisURL, "rediss://"))
+		} else {
+			log.Printf("✓ Redis: %s:%d (TLS: %t)", cfg.RedisHost, cfg.RedisPort, cfg.RedisTLS)
+		}
+		if len(quotas) > 0 {
+			log.Printf("✓ Quotas: %d tokens (time zone %s)", len(quotas), quotaLocation)
+		}
+		log.Printf("✓ Failure Mode: %s", failureMode)
+		if cfg.LocalCacheEnabled {
+			log.Printf("✓ Local Cache: enabled (batch size %d)", cfg.LocalCacheBatchSize)
+		}
+		if notifier != nil {
+			log.Printf("✓ Webhooks: thresholds %s", cfg.WebhookThresholds)
+		}
+		if usageRecorder != nil {
+			log.Printf("✓ Usage Accounting: flushed every %dms, kept for %d days", cfg.UsageFlushIntervalMs, cfg.UsageRetentionDays)
+		}
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
@@ -99,13 +371,65 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			log.Printf("Admin server shutdown error: %v", err)
+		}
+	}
+
+	if rlsServer != nil {
+		rlsServer.GracefulStop()
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server shutdown error: %v", err)
 	}
 
+	// Deliver the queued notifications before exiting
+	if notifier != nil {
+		if err := notifier.Close(ctx); err != nil {
+			log.Printf("Webhook shutdown error: %v", err)
+		}
+	}
+
+	// Write the usage of the last requests before exiting
+	if usageRecorder != nil {
+		if err := usageRecorder.Close(ctx); err != nil {
+			log.Printf("Usage flush error: %v", err)
+		}
+	}
+
 	log.Println("✓ Server stopped gracefully")
 }
 
+// redisOptions maps the configuration to the Redis connection options
+func redisOptions(cfg *config.Config) ratelimit.RedisOptions {
+	return ratelimit.RedisOptions{
+		Mode:                  cfg.RedisMode,
+		Addrs:                 cfg.RedisAddrs,
+		MasterName:            cfg.RedisMasterName,
+		SentinelUsername:      cfg.RedisSentinelUsername,
+		SentinelPassword:      cfg.RedisSentinelPassword,
+		URL:                   cfg.RedisURL,
+		Host:                  cfg.RedisHost,
+		Port:                  cfg.RedisPort,
+		DB:                    cfg.RedisDB,
+		Username:              cfg.RedisUsername,
+		Password:              cfg.RedisPassword,
+		TLSEnabled:            cfg.RedisTLS,
+		TLSCAFile:             cfg.RedisTLSCAFile,
+		TLSCertFile:           cfg.RedisTLSCertFile,
+		TLSKeyFile:            cfg.RedisTLSKeyFile,
+		TLSInsecureSkipVerify: cfg.RedisTLSInsecureSkipVerify,
+		PoolSize:              cfg.RedisPoolSize,
+		MinIdleConns:          cfg.RedisMinIdleConns,
+		MaxRetries:            cfg.RedisMaxRetries,
+		DialTimeoutMs:         cfg.RedisDialTimeoutMs,
+		ReadTimeoutMs:         cfg.RedisReadTimeoutMs,
+		WriteTimeoutMs:        cfg.RedisWriteTimeoutMs,
+	}
+}
+
 func handleRequest(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
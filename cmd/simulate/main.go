@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/config"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/simulator"
+)
+
+// simulate replays an access log through the limiter with a virtual clock
+// and compares candidate limits, e.g.
+//
+//	go run ./cmd/simulate -log access.log -policies current=5/300,relaxed=20/60
+func main() {
+	// The current configuration is the default candidate
+	cfg := config.LoadConfig()
+
+	logPath := flag.String("log", "-", "access log to replay, JSON Lines or common/combined log format (- for stdin)")
+	format := flag.String("format", simulator.FormatAuto, "log format: auto, jsonl or clf")
+	policies := flag.String("policies", fmt.Sprintf("current=%d/%d", cfg.RateLimitIP, cfg.IPBlockDuration), "candidate limits compared: name=limit/seconds separated by commas")
+	tokens := flag.String("tokens", fmt.Sprintf("token123=%d/%d,premium-token=100/60", cfg.RateLimitToken, cfg.TokenBlockDuration), "token limits used by every candidate: token=limit/seconds separated by commas")
+	routeDepth := flag.Int("route-depth", 2, "path segments that identify a route (0 keeps the full path)")
+	top := flag.Int("top", 20, "number of most denied keys to list")
+	flag.Parse()
+
+	candidates, err := limiter.ParsePolicies(*policies)
+	if err != nil {
+		log.Fatalf("Invalid -policies: %v", err)
+	}
+	tokenLimits, err := limiter.ParsePolicies(*tokens)
+	if err != nil {
+		log.Fatalf("Invalid -tokens: %v", err)
+	}
+
+	var input io.Reader = os.Stdin
+	if *logPath != "-" {
+		file, err := os.Open(*logPath)
+		if err != nil {
+			log.Fatalf("Failed to open log: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	entries, skipped, err := simulator.ParseLog(input, *format)
+	if err != nil {
+		log.Fatalf("Failed to read log: %v", err)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d lines that could not be parsed or have no client", skipped)
+	}
+	if len(entries) == 0 {
+		log.Fatalf("No requests to replay")
+	}
+
+	reports, err := simulator.Run(context.Background(), entries, simulator.Config{
+		Candidates: candidates,
+		Tokens:     tokenLimits,
+		RouteDepth: *routeDepth,
+	})
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+
+	fmt.Printf("Replayed %d requests from %s to %s\n\n", len(entries), entries[0].Time.Format("2006-01-02 15:04:05"), entries[len(entries)-1].Time.Format("2006-01-02 15:04:05"))
+	if err := simulator.WriteReport(os.Stdout, reports, *top); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
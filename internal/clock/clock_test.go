@@ -0,0 +1,24 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFake tests that the fake clock only moves when told to
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+
+	if !fake.Now().Equal(start) {
+		t.Fatalf("Expected %v, got %v", start, fake.Now())
+	}
+	fake.Advance(90 * time.Second)
+	if !fake.Now().Equal(start.Add(90 * time.Second)) {
+		t.Fatalf("Expected the clock to advance, got %v", fake.Now())
+	}
+	fake.Set(start)
+	if !fake.Now().Equal(start) {
+		t.Fatalf("Expected the clock to go back, got %v", fake.Now())
+	}
+}
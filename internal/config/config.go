@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -21,8 +22,104 @@ type Config struct {
 	RedisPort int
 	RedisDB   int
 
+	// Redis high availability (sentinel or cluster)
+	RedisMode             string
+	RedisAddrs            []string
+	RedisMasterName       string
+	RedisSentinelUsername string
+	RedisSentinelPassword string
+
+	// Redis connection options (REDIS_URL takes precedence over host/port)
+	RedisURL                   string
+	RedisUsername              string
+	RedisPassword              string
+	RedisTLS                   bool
+	RedisTLSCAFile             string
+	RedisTLSCertFile           string
+	RedisTLSKeyFile            string
+	RedisTLSInsecureSkipVerify bool
+	RedisPoolSize              int
+	RedisMinIdleConns          int
+	RedisMaxRetries            int
+	RedisDialTimeoutMs         int
+	RedisReadTimeoutMs         int
+	RedisWriteTimeoutMs        int
+
 	// Server configuration
 	ServerPort int
+	AdminPort  int
+
+	// Health checks
+	ReadinessTimeoutMs int
+
+	// Stacked quotas per token, aligned to calendar boundaries in the time zone
+	Quotas        string
+	QuotaTimezone string
+
+	// Usage accounting per token and hour for billing (queried on the admin port)
+	UsageEnabled         bool
+	UsageFlushIntervalMs int
+	UsageRetentionDays   int
+
+	// Threshold webhooks (enabled when the URL is set)
+	WebhookURL        string
+	WebhookSecret     string
+	WebhookThresholds string
+
+	// Request cost (route rules and a header set by trusted upstreams)
+	CostRules  string
+	CostHeader string
+
+	// In-flight request limits (leases renewed while the request runs)
+	ConcurrencyLimit        int
+	ConcurrencyRules        string
+	ConcurrencyTokens       string
+	ConcurrencyLeaseSeconds int
+
+	// Shadow policies compared with the enforced limits per route
+	ShadowRules string
+
+	// Decision service (POST /v1/check)
+	DecisionAPIEnabled bool
+	Policies           string
+
+	// Forward-auth endpoint for nginx auth_request and Traefik/Caddy
+	ForwardAuthEnabled    bool
+	ForwardAuthDenyStatus int
+
+	// Envoy rate limit service (gRPC, disabled when the port is 0)
+	RLSPort  int
+	RLSRules string
+
+	// Reverse-proxy mode (enabled when routes are set)
+	ProxyRoutes      string
+	ProxyTimeoutMs   int
+	ProxyStripPrefix bool
+	// Proxies allowed to set X-Forwarded-For, as IPs or CIDRs
+	ProxyTrustedProxies string
+
+	// Local cache in front of the storage
+	LocalCacheEnabled     bool
+	LocalCacheBatchSize   int
+	LocalCacheStalenessMs int
+	LocalCacheMaxKeys     int
+
+	// Storage failure handling
+	FailureMode             string
+	CircuitBreakerThreshold int
+	CircuitBreakerTimeout   int
+	LimiterTimeoutMs        int
+
+	// Fault injection in front of Redis, for chaos testing in staging only
+	FaultInjectionEnabled bool
+	FaultLatencyMs        int
+	FaultJitterMs         int
+	FaultErrorRate        float64
+	FaultTimeoutRate      float64
+	FaultTimeoutMs        int
+	FaultLostReplyRate    float64
+	FaultExpireErrorRate  float64
+	FaultSplitConsume     bool
 }
 
 func LoadConfig() *Config {
@@ -37,7 +134,87 @@ func LoadConfig() *Config {
 		RedisHost:          getEnv("REDIS_HOST", "localhost"),
 		RedisPort:          getEnvAsInt("REDIS_PORT", 6379),
 		RedisDB:            getEnvAsInt("REDIS_DB", 0),
+
+		RedisMode:             getEnv("REDIS_MODE", "standalone"),
+		RedisAddrs:            getEnvAsList("REDIS_ADDRS"),
+		RedisMasterName:       getEnv("REDIS_MASTER_NAME", ""),
+		RedisSentinelUsername: getEnv("REDIS_SENTINEL_USERNAME", ""),
+		RedisSentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+
+		RedisURL:                   getEnv("REDIS_URL", ""),
+		RedisUsername:              getEnv("REDIS_USERNAME", ""),
+		RedisPassword:              getEnv("REDIS_PASSWORD", ""),
+		RedisTLS:                   getEnvAsBool("REDIS_TLS", false),
+		RedisTLSCAFile:             getEnv("REDIS_TLS_CA_FILE", ""),
+		RedisTLSCertFile:           getEnv("REDIS_TLS_CERT_FILE", ""),
+		RedisTLSKeyFile:            getEnv("REDIS_TLS_KEY_FILE", ""),
+		RedisTLSInsecureSkipVerify: getEnvAsBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+		RedisPoolSize:              getEnvAsInt("REDIS_POOL_SIZE", 0),
+		RedisMinIdleConns:          getEnvAsInt("REDIS_MIN_IDLE_CONNS", 0),
+		RedisMaxRetries:            getEnvAsInt("REDIS_MAX_RETRIES", 0),
+		RedisDialTimeoutMs:         getEnvAsInt("REDIS_DIAL_TIMEOUT_MS", 0),
+		RedisReadTimeoutMs:         getEnvAsInt("REDIS_READ_TIMEOUT_MS", 0),
+		RedisWriteTimeoutMs:        getEnvAsInt("REDIS_WRITE_TIMEOUT_MS", 0),
+
 		ServerPort:         getEnvAsInt("SERVER_PORT", 8080),
+		AdminPort:          getEnvAsInt("ADMIN_PORT", 0),
+		ReadinessTimeoutMs: getEnvAsInt("READINESS_TIMEOUT_MS", 1000),
+
+		Quotas:        getEnv("QUOTAS", ""),
+		QuotaTimezone: getEnv("QUOTA_TIMEZONE", "UTC"),
+
+		UsageEnabled:         getEnvAsBool("USAGE_ENABLED", false),
+		UsageFlushIntervalMs: getEnvAsInt("USAGE_FLUSH_INTERVAL_MS", 5000),
+		UsageRetentionDays:   getEnvAsInt("USAGE_RETENTION_DAYS", 400),
+
+		WebhookURL:        getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:     getEnv("WEBHOOK_SECRET", ""),
+		WebhookThresholds: getEnv("WEBHOOK_THRESHOLDS", "blocked"),
+
+		CostRules:  getEnv("COST_RULES", ""),
+		CostHeader: getEnv("COST_HEADER", ""),
+
+		ConcurrencyLimit:        getEnvAsInt("CONCURRENCY_LIMIT", 0),
+		ConcurrencyRules:        getEnv("CONCURRENCY_RULES", ""),
+		ConcurrencyTokens:       getEnv("CONCURRENCY_TOKENS", ""),
+		ConcurrencyLeaseSeconds: getEnvAsInt("CONCURRENCY_LEASE_SECONDS", 60),
+
+		ShadowRules: getEnv("SHADOW_RULES", ""),
+
+		DecisionAPIEnabled: getEnvAsBool("DECISION_API_ENABLED", false),
+		Policies:           getEnv("POLICIES", ""),
+
+		ForwardAuthEnabled:    getEnvAsBool("FORWARD_AUTH_ENABLED", false),
+		ForwardAuthDenyStatus: getEnvAsInt("FORWARD_AUTH_DENY_STATUS", 429),
+
+		RLSPort:  getEnvAsInt("RLS_PORT", 0),
+		RLSRules: getEnv("RLS_RULES", ""),
+
+		ProxyRoutes:      getEnv("PROXY_ROUTES", ""),
+		ProxyTimeoutMs:   getEnvAsInt("PROXY_TIMEOUT_MS", 30000),
+		ProxyStripPrefix: getEnvAsBool("PROXY_STRIP_PREFIX", false),
+
+		ProxyTrustedProxies: getEnv("PROXY_TRUSTED_PROXIES", ""),
+
+		LocalCacheEnabled:     getEnvAsBool("LOCAL_CACHE_ENABLED", false),
+		LocalCacheBatchSize:   getEnvAsInt("LOCAL_CACHE_BATCH_SIZE", 0),
+		LocalCacheStalenessMs: getEnvAsInt("LOCAL_CACHE_MAX_STALENESS_MS", 100),
+		LocalCacheMaxKeys:     getEnvAsInt("LOCAL_CACHE_MAX_KEYS", 100000),
+
+		FailureMode:             getEnv("FAILURE_MODE", "closed"),
+		CircuitBreakerThreshold: getEnvAsInt("CIRCUIT_BREAKER_THRESHOLD", 5),
+		CircuitBreakerTimeout:   getEnvAsInt("CIRCUIT_BREAKER_TIMEOUT", 30),
+		LimiterTimeoutMs:        getEnvAsInt("LIMITER_TIMEOUT_MS", 0),
+
+		FaultInjectionEnabled: getEnvAsBool("FAULT_INJECTION_ENABLED", false),
+		FaultLatencyMs:        getEnvAsInt("FAULT_LATENCY_MS", 0),
+		FaultJitterMs:         getEnvAsInt("FAULT_JITTER_MS", 0),
+		FaultErrorRate:        getEnvAsFloat("FAULT_ERROR_RATE", 0),
+		FaultTimeoutRate:      getEnvAsFloat("FAULT_TIMEOUT_RATE", 0),
+		FaultTimeoutMs:        getEnvAsInt("FAULT_TIMEOUT_MS", 1000),
+		FaultLostReplyRate:    getEnvAsFloat("FAULT_LOST_REPLY_RATE", 0),
+		FaultExpireErrorRate:  getEnvAsFloat("FAULT_EXPIRE_ERROR_RATE", 0),
+		FaultSplitConsume:     getEnvAsBool("FAULT_SPLIT_CONSUME", false),
 	}
 }
 
@@ -55,3 +232,29 @@ func getEnvAsInt(name string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvAsBool(name string, defaultVal bool) bool {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+func getEnvAsFloat(name string, defaultVal float64) float64 {
+	valueStr := getEnv(name, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultVal
+}
+
+func getEnvAsList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(name, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,199 @@
+package decision
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// maxBatchSize bounds the number of descriptors accepted in one batch call
+const maxBatchSize = 100
+
+// Descriptor identifies what is being limited: the key parts are joined to
+// build the counter key and cost units are consumed from the named policy
+type Descriptor struct {
+	Policy string   `json:"policy"`
+	Key    []string `json:"key"`
+	Cost   *int     `json:"cost,omitempty"`
+}
+
+// Result is the decision for a single descriptor
+type Result struct {
+	Allowed   bool `json:"allowed"`
+	Limit     int  `json:"limit"`
+	Remaining int  `json:"remaining"`
+	Reset     int  `json:"reset"`
+	// ShadowDenied is set when the policy runs in shadow mode and would have denied
+	ShadowDenied bool   `json:"shadow_denied,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// BatchRequest holds many descriptors checked in one call
+type BatchRequest struct {
+	Descriptors []Descriptor `json:"descriptors"`
+}
+
+// BatchResponse holds one result per descriptor, in request order
+type BatchResponse struct {
+	Allowed bool     `json:"allowed"`
+	Results []Result `json:"results"`
+}
+
+// QuotaWindow is the usage of one quota window of a token
+type QuotaWindow struct {
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// QuotaResponse holds the usage of every quota window of a token
+type QuotaResponse struct {
+	Token   string        `json:"token"`
+	Windows []QuotaWindow `json:"windows"`
+}
+
+// Handler exposes RateLimiter.Check over HTTP for external callers
+type Handler struct {
+	rl *limiter.RateLimiter
+}
+
+func NewHandler(rl *limiter.RateLimiter) *Handler {
+	return &Handler{rl: rl}
+}
+
+// Register mounts POST /v1/check, POST /v1/check/batch and GET /v1/quota on the mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/check", h.handleCheck)
+	mux.HandleFunc("/v1/check/batch", h.handleBatch)
+	mux.HandleFunc("/v1/quota", h.handleQuota)
+}
+
+func (h *Handler) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var descriptor Descriptor
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&descriptor); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	result, status := h.check(r, descriptor)
+	writeJSON(w, status, result)
+}
+
+func (h *Handler) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var request BatchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(request.Descriptors) == 0 || len(request.Descriptors) > maxBatchSize {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "descriptors must contain between 1 and 100 entries"})
+		return
+	}
+
+	// Descriptors are evaluated independently, a denied one does not undo the others
+	response := BatchResponse{Allowed: true, Results: make([]Result, len(request.Descriptors))}
+	for i, descriptor := range request.Descriptors {
+		result, _ := h.check(r, descriptor)
+		response.Results[i] = result
+		if !result.Allowed {
+			response.Allowed = false
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "token must not be empty"})
+		return
+	}
+
+	usage, err := h.rl.QuotaUsage(r.Context(), token)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "storage unavailable"})
+		return
+	}
+	if usage == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "token has no quotas"})
+		return
+	}
+
+	response := QuotaResponse{Token: token, Windows: make([]QuotaWindow, len(usage))}
+	for i, window := range usage {
+		response.Windows[i] = QuotaWindow{
+			Period:    string(window.Period),
+			Limit:     window.Limit,
+			Used:      window.Used,
+			Remaining: window.Remaining,
+			ResetsAt:  window.ResetsAt,
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// check evaluates one descriptor and returns its result with the matching HTTP status
+func (h *Handler) check(r *http.Request, descriptor Descriptor) (Result, int) {
+	if descriptor.Policy == "" {
+		descriptor.Policy = limiter.DefaultPolicy
+	}
+	if len(descriptor.Key) == 0 {
+		return Result{Error: "key must not be empty"}, http.StatusBadRequest
+	}
+
+	cost := 1
+	if descriptor.Cost != nil {
+		cost = *descriptor.Cost
+	}
+
+	decision, err := h.rl.Check(r.Context(), descriptor.Policy, descriptor.Key, cost)
+	result := Result{
+		Allowed:      decision.Allowed,
+		Limit:        decision.Limit,
+		Remaining:    decision.Remaining,
+		Reset:        int(math.Ceil(decision.Reset.Seconds())),
+		ShadowDenied: decision.ShadowDenied,
+	}
+
+	switch {
+	case errors.Is(err, limiter.ErrUnknownPolicy):
+		result.Error = err.Error()
+		return result, http.StatusBadRequest
+	case errors.Is(err, limiter.ErrStorageUnavailable):
+		result.Error = "storage unavailable"
+		return result, http.StatusServiceUnavailable
+	case err != nil:
+		result.Error = err.Error()
+		return result, http.StatusBadRequest
+	}
+
+	return result, http.StatusOK
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,136 @@
+package decision
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+func newTestMux() *http.ServeMux {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("export", 10, 3600)
+
+	mux := http.NewServeMux()
+	NewHandler(rl).Register(mux)
+	return mux
+}
+
+func post(mux *http.ServeMux, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+// TestCheck tests a single descriptor consuming cost units from a policy
+func TestCheck(t *testing.T) {
+	mux := newTestMux()
+
+	w := post(mux, "/v1/check", `{"policy":"export","key":["tenant:acme","user:42"],"cost":6}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var result Result
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.Allowed || result.Limit != 10 || result.Remaining != 4 || result.Reset != 3600 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	// The remaining 4 units cannot cover another 6
+	w = post(mux, "/v1/check", `{"policy":"export","key":["tenant:acme","user:42"],"cost":6}`)
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Allowed || result.Remaining != 4 {
+		t.Fatalf("Expected denial keeping 4 remaining, got %+v", result)
+	}
+}
+
+// TestCheckErrors tests validation of descriptors
+func TestCheckErrors(t *testing.T) {
+	mux := newTestMux()
+
+	cases := map[string]string{
+		"unknown policy": `{"policy":"missing","key":["a"]}`,
+		"empty key":      `{"policy":"export","key":[]}`,
+		"negative cost":  `{"policy":"export","key":["a"],"cost":-1}`,
+		"invalid body":   `{`,
+	}
+	for name, body := range cases {
+		if w := post(mux, "/v1/check", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", name, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/v1/check", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", w.Code)
+	}
+}
+
+// TestCheckBatch tests that each descriptor gets its own result
+func TestCheckBatch(t *testing.T) {
+	mux := newTestMux()
+
+	w := post(mux, "/v1/check/batch", `{"descriptors":[
+		{"key":["ip:10.0.0.1"]},
+		{"policy":"export","key":["tenant:acme"],"cost":11},
+		{"policy":"missing","key":["a"]}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response BatchResponse
+	json.NewDecoder(w.Body).Decode(&response)
+
+	if response.Allowed || len(response.Results) != 3 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+	if !response.Results[0].Allowed || response.Results[0].Limit != 5 {
+		t.Fatalf("Default policy descriptor should be allowed, got %+v", response.Results[0])
+	}
+	if response.Results[1].Allowed {
+		t.Fatal("Cost above the limit should be denied")
+	}
+	if response.Results[2].Error == "" {
+		t.Fatal("Unknown policy should report an error")
+	}
+}
+
+// TestQuota tests the usage report of a token with quotas
+func TestQuota(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigureQuotas("plan-token", limiter.QuotaWindow{Period: limiter.PeriodMonth, Limit: 1000}, limiter.QuotaWindow{Period: limiter.PeriodSecond, Limit: 10})
+	mux := http.NewServeMux()
+	NewHandler(rl).Register(mux)
+
+	rl.AllowN(context.Background(), "10.0.0.1", "plan-token", 3)
+
+	req := httptest.NewRequest("GET", "/v1/quota?token=plan-token", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response QuotaResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.Windows) != 2 || response.Windows[0].Period != "second" || response.Windows[1].Used != 3 || response.Windows[1].Remaining != 997 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/quota?token=unknown", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 for a token without quotas, got %d", w.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// Checker reports liveness and readiness of the rate limiter service
+type Checker struct {
+	storage     strategy.StorageStrategy
+	breaker     *strategy.CircuitBreakerStorage
+	timeout     time.Duration
+	failureMode limiter.FailureMode
+	loadedAt    time.Time
+	reload      bool
+}
+
+// NewChecker creates a health checker that pings the storage with the given timeout.
+// The circuit breaker is optional and only used to report its state. Unless
+// the failure mode is FailClosed, requests are still served while the storage
+// is down, so an unreachable storage only reports the service as degraded.
+func NewChecker(storage strategy.StorageStrategy, breaker *strategy.CircuitBreakerStorage, timeout time.Duration, failureMode limiter.FailureMode) *Checker {
+	return &Checker{
+		storage:     storage,
+		breaker:     breaker,
+		timeout:     timeout,
+		failureMode: failureMode,
+		loadedAt:    time.Now(),
+	}
+}
+
+// SetConfig records when the configuration was loaded and whether it is
+// reloaded while running, as reported by the readiness check
+func (c *Checker) SetConfig(loadedAt time.Time, reload bool) {
+	c.loadedAt = loadedAt
+	c.reload = reload
+}
+
+// StorageStatus is the result of the storage health check
+type StorageStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// ConfigStatus describes the loaded configuration
+type ConfigStatus struct {
+	Reload   string `json:"reload"`
+	LoadedAt string `json:"loaded_at"`
+}
+
+// Report is the readiness response body
+type Report struct {
+	Status         string         `json:"status"`
+	Storage        *StorageStatus `json:"storage,omitempty"`
+	CircuitBreaker string         `json:"circuit_breaker,omitempty"`
+	Config         *ConfigStatus  `json:"config,omitempty"`
+}
+
+// Readiness statuses
+const (
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not_ready"
+)
+
+// Check pings the storage and builds the readiness report
+func (c *Checker) Check(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := strategy.Ping(ctx, c.storage)
+
+	storage := &StorageStatus{
+		Status:    "ok",
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	reload := "disabled"
+	if c.reload {
+		reload = "enabled"
+	}
+	report := Report{
+		Status:  StatusReady,
+		Storage: storage,
+		Config: &ConfigStatus{
+			Reload:   reload,
+			LoadedAt: c.loadedAt.Format(time.RFC3339),
+		},
+	}
+
+	if err != nil {
+		storage.Status = "error"
+		storage.Error = err.Error()
+		report.Status = StatusNotReady
+		if c.failureMode != limiter.FailClosed {
+			report.Status = StatusDegraded
+		}
+	}
+
+	if c.breaker != nil {
+		report.CircuitBreaker = c.breaker.State().String()
+	}
+
+	return report
+}
+
+// LivenessHandler reports that the process is running, without touching the storage
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// ReadinessHandler reports whether the service can take traffic, 503 only when
+// it is not ready. Without details only the overall status is returned.
+func (c *Checker) ReadinessHandler(details bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+
+		status := http.StatusOK
+		if report.Status == StatusNotReady {
+			status = http.StatusServiceUnavailable
+		}
+
+		if !details {
+			report = Report{Status: report.Status}
+		}
+
+		writeJSON(w, status, report)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
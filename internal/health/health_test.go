@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// downStorage simulates an unreachable storage
+type downStorage struct {
+	*strategy.MemoryStorage
+}
+
+func (d *downStorage) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+// TestReadinessReady tests that a reachable storage reports ready with details
+func TestReadinessReady(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	breaker := strategy.NewCircuitBreakerStorage(storage, 5, 30)
+	checker := NewChecker(breaker, breaker, time.Second, limiter.FailClosed)
+
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler(true)(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var report Report
+	json.NewDecoder(w.Body).Decode(&report)
+
+	if report.Storage == nil || report.Storage.Status != "ok" {
+		t.Fatalf("Expected storage ok, got %+v", report.Storage)
+	}
+	if report.CircuitBreaker != "closed" {
+		t.Fatalf("Expected closed circuit breaker, got %q", report.CircuitBreaker)
+	}
+}
+
+// TestReadinessNotReady tests that an unreachable storage reports 503 and hides details
+func TestReadinessNotReady(t *testing.T) {
+	checker := NewChecker(&downStorage{strategy.NewMemoryStorage()}, nil, time.Second, limiter.FailClosed)
+
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler(false)(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+
+	var report Report
+	json.NewDecoder(w.Body).Decode(&report)
+
+	if report.Status != "not_ready" {
+		t.Fatalf("Expected not_ready, got %q", report.Status)
+	}
+	if report.Storage != nil {
+		t.Fatal("Storage details should not be exposed without details")
+	}
+}
+
+// TestReadinessDegraded tests that a service still serving requests while the
+// storage is down stays in rotation
+func TestReadinessDegraded(t *testing.T) {
+	for _, mode := range []limiter.FailureMode{limiter.FailOpen, limiter.FailLocal} {
+		checker := NewChecker(&downStorage{strategy.NewMemoryStorage()}, nil, time.Second, mode)
+		checker.SetConfig(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), true)
+
+		w := httptest.NewRecorder()
+		checker.ReadinessHandler(true)(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", mode, w.Code)
+		}
+
+		var report Report
+		json.NewDecoder(w.Body).Decode(&report)
+
+		if report.Status != StatusDegraded || report.Storage.Status != "error" {
+			t.Fatalf("%s: expected degraded with a storage error, got %+v", mode, report)
+		}
+		if report.Config.Reload != "enabled" || report.Config.LoadedAt != "2026-10-19T12:00:00Z" {
+			t.Fatalf("%s: unexpected config status %+v", mode, report.Config)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// Interceptor applies the rate limiter to gRPC calls. Clients are identified by
+// the api_key or authorization metadata, falling back to the peer address.
+// Methods listed in the method policies use that policy through
+// RateLimiter.Check; every other method uses the IP/token limits of Allow.
+type Interceptor struct {
+	rl             *limiter.RateLimiter
+	methodPolicies map[string]string
+}
+
+// New creates the interceptor. methodPolicies maps a full method name
+// ("/pkg.Service/Method") or a whole service ("/pkg.Service/*") to a policy,
+// every policy must be configured in the limiter.
+func New(rl *limiter.RateLimiter, methodPolicies map[string]string) (*Interceptor, error) {
+	for method, policy := range methodPolicies {
+		if _, exists := rl.Policy(policy); !exists {
+			return nil, fmt.Errorf("%w: %s (method %s)", limiter.ErrUnknownPolicy, policy, method)
+		}
+	}
+	return &Interceptor{rl: rl, methodPolicies: methodPolicies}, nil
+}
+
+// Unary returns the unary server interceptor
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		decision, err := i.decide(ctx, info.FullMethod)
+		if decided(err) {
+			grpc.SetHeader(ctx, headers(decision))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Stream returns the stream server interceptor, the limit is applied once when the stream opens
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		decision, err := i.decide(ss.Context(), info.FullMethod)
+		if decided(err) {
+			ss.SetHeader(headers(decision))
+		}
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// decide runs the limiter for the call and converts denials and failures to gRPC statuses
+func (i *Interceptor) decide(ctx context.Context, fullMethod string) (limiter.Decision, error) {
+	ip, token := clientIdentity(ctx)
+
+	var decision limiter.Decision
+	var err error
+	if policy, exists := i.policyFor(fullMethod); exists {
+		id := "ip:" + ip
+		if token != "" {
+			id = "token:" + token
+		}
+		decision, err = i.rl.Check(ctx, policy, []string{id}, 1)
+	} else {
+		decision, err = i.rl.Decide(ctx, ip, token)
+	}
+
+	switch {
+	case errors.Is(err, limiter.ErrStorageUnavailable):
+		return decision, status.Error(codes.Unavailable, "rate limiter storage unavailable")
+	case err != nil:
+		return decision, status.Error(codes.Internal, err.Error())
+	case !decision.Allowed:
+		return decision, exhausted(decision)
+	}
+	return decision, nil
+}
+
+// policyFor returns the policy of the exact method, then of its service
+func (i *Interceptor) policyFor(fullMethod string) (string, bool) {
+	if policy, exists := i.methodPolicies[fullMethod]; exists {
+		return policy, true
+	}
+	if slash := strings.LastIndex(fullMethod, "/"); slash > 0 {
+		if policy, exists := i.methodPolicies[fullMethod[:slash]+"/*"]; exists {
+			return policy, true
+		}
+	}
+	return "", false
+}
+
+// clientIdentity reads the token from metadata and the IP from the peer address
+func clientIdentity(ctx context.Context) (string, string) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("api_key"); len(values) > 0 {
+			token = values[0]
+		} else if values := md.Get("authorization"); len(values) > 0 {
+			token = strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer "))
+		}
+	}
+
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	return ip, token
+}
+
+// exhausted builds the RESOURCE_EXHAUSTED status with the retry delay in its details
+func exhausted(decision limiter.Decision) error {
+	st := status.New(codes.ResourceExhausted, "you have reached the maximum number of requests or actions allowed within a certain time frame")
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.Reset)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// decided reports whether the limiter reached a decision, so rate limit headers are meaningful
+func decided(err error) bool {
+	code := status.Code(err)
+	return code == codes.OK || code == codes.ResourceExhausted
+}
+
+// headers returns the x-ratelimit-* response metadata
+func headers(decision limiter.Decision) metadata.MD {
+	return metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(decision.Limit),
+		"x-ratelimit-remaining", strconv.Itoa(decision.Remaining),
+		"x-ratelimit-reset", strconv.Itoa(int(math.Ceil(decision.Reset.Seconds()))),
+	)
+}
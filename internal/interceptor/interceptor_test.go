@@ -0,0 +1,143 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// newTestClient serves the gRPC health service behind the interceptors on an in-memory listener
+func newTestClient(t *testing.T, rl *limiter.RateLimiter, methodPolicies map[string]string) healthpb.HealthClient {
+	interceptor, err := New(rl, methodPolicies)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(interceptor.Unary()),
+		grpc.StreamInterceptor(interceptor.Stream()),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+// TestUnaryInterceptor tests that unary calls above the limit get RESOURCE_EXHAUSTED with retry info
+func TestUnaryInterceptor(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 2, 60)
+	client := newTestClient(t, rl, nil)
+
+	for i := 0; i < 2; i++ {
+		var header metadata.MD
+		if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("Call %d should be allowed: %v", i+1, err)
+		}
+		if i == 0 && (header.Get("x-ratelimit-limit")[0] != "2" || header.Get("x-ratelimit-remaining")[0] != "1") {
+			t.Fatalf("Unexpected header %v", header)
+		}
+	}
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected RESOURCE_EXHAUSTED, got %v", err)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("Expected retry info in details, got %v", st.Details())
+	}
+	if retry, ok := st.Details()[0].(*errdetails.RetryInfo); !ok || retry.RetryDelay.AsDuration() <= 0 || retry.RetryDelay.AsDuration().Seconds() > 60 {
+		t.Fatalf("Unexpected details %v", st.Details())
+	}
+
+	// A token in metadata has its own counter
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer premium")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Token call should be allowed: %v", err)
+	}
+}
+
+// TestMethodPolicies tests exact and per-service method policies
+func TestMethodPolicies(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 100, 60)
+	rl.ConfigurePolicy("strict", 1, 60)
+
+	client := newTestClient(t, rl, map[string]string{"/grpc.health.v1.Health/Check": "strict"})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "api_key", "tenant-a")
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("First call should be allowed: %v", err)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Second call should be denied by the method policy, got %v", err)
+	}
+
+	interceptor, _ := New(rl, map[string]string{"/grpc.health.v1.Health/*": "strict"})
+	if policy, exists := interceptor.policyFor("/grpc.health.v1.Health/Watch"); !exists || policy != "strict" {
+		t.Fatalf("Service wildcard should match, got %q", policy)
+	}
+	if _, exists := interceptor.policyFor("/other.Service/Call"); exists {
+		t.Fatal("Other services should not match")
+	}
+}
+
+// TestNewRejectsUnknownPolicies tests that method policies are validated when the interceptor is created
+func TestNewRejectsUnknownPolicies(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 100, 60)
+
+	if _, err := New(rl, map[string]string{"/grpc.health.v1.Health/*": "missing"}); !errors.Is(err, limiter.ErrUnknownPolicy) {
+		t.Fatalf("Expected ErrUnknownPolicy, got %v", err)
+	}
+}
+
+// TestStreamInterceptor tests that the limit is applied when a stream opens
+func TestStreamInterceptor(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 1, 60)
+	client := newTestClient(t, rl, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("First stream should be allowed: %v", err)
+	}
+
+	stream, err = client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Second stream should be denied, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// benchLimit is high enough that benchmarks measure allowed requests, the
+// denied path is measured separately
+const benchLimit = 1 << 30
+
+var benchCardinalities = []int{1, 1000, 100000}
+
+// benchBackends builds each storage measured, with the decorators the server
+// can put in front of it. Redis runs embedded, or for real when REDIS_URL is set.
+func benchBackends(b *testing.B) map[string]func(b *testing.B) strategy.StorageStrategy {
+	backends := map[string]func(b *testing.B) strategy.StorageStrategy{
+		"memory": func(b *testing.B) strategy.StorageStrategy {
+			return strategy.NewMemoryStorage()
+		},
+		"breaker": func(b *testing.B) strategy.StorageStrategy {
+			return strategy.NewCircuitBreakerStorage(strategy.NewMemoryStorage(), 5, 30)
+		},
+		"cached": func(b *testing.B) strategy.StorageStrategy {
+			return strategy.NewCachedStorage(strategy.NewMemoryStorage(), 0, 100, 100000)
+		},
+		// Batched increments do not support Consume, so this also measures the non-atomic path
+		"cached-batch": func(b *testing.B) strategy.StorageStrategy {
+			return strategy.NewCachedStorage(strategy.NewMemoryStorage(), 10, 100, 100000)
+		},
+		"miniredis": func(b *testing.B) strategy.StorageStrategy {
+			server := miniredis.NewMiniRedis()
+			if err := server.Start(); err != nil {
+				b.Fatalf("Unexpected error: %v", err)
+			}
+			b.Cleanup(server.Close)
+			return strategy.NewRedisStorageFromClient(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+		},
+	}
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		backends["redis"] = func(b *testing.B) strategy.StorageStrategy {
+			storage, err := strategy.NewRedisStorageWithOptions(strategy.RedisOptions{URL: url})
+			if err != nil {
+				b.Fatalf("Unexpected error: %v", err)
+			}
+			return storage
+		}
+	}
+	return backends
+}
+
+// benchDecisions are the decision paths measured, each called with the index of a client
+var benchDecisions = map[string]func(ctx context.Context, rl *RateLimiter, clients []string, i int){
+	"allow": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.Allow(ctx, clients[i%len(clients)], "")
+	},
+	"allow-cost": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.AllowN(ctx, clients[i%len(clients)], "", 5)
+	},
+	"decide": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.Decide(ctx, clients[i%len(clients)], "")
+	},
+	"quotas": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.Allow(ctx, "", clients[i%len(clients)])
+	},
+	"policy": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.Check(ctx, "search", []string{"ip:" + clients[i%len(clients)]}, 1)
+	},
+	"denied": func(ctx context.Context, rl *RateLimiter, clients []string, i int) {
+		rl.Allow(ctx, "", "blocked")
+	},
+}
+
+func newBenchLimiter(storage strategy.StorageStrategy, clients []string) *RateLimiter {
+	rl := NewRateLimiter(storage, benchLimit, 300)
+	rl.ConfigurePolicy("search", benchLimit, 60)
+	rl.ConfigureToken("blocked", 0, 300)
+	for _, client := range clients {
+		rl.ConfigureQuotas(client, QuotaWindow{Period: PeriodSecond, Limit: benchLimit}, QuotaWindow{Period: PeriodMonth, Limit: benchLimit})
+	}
+	return rl
+}
+
+func sortedNames[T any](values map[string]T) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func benchClients(n int) []string {
+	clients := make([]string, n)
+	for i := range clients {
+		clients[i] = fmt.Sprintf("10.%d.%d.%d", i>>16&255, i>>8&255, i&255)
+	}
+	return clients
+}
+
+// BenchmarkDecisions measures each decision path on each backend, with 1 to
+// 100,000 distinct clients, e.g. go test -bench 'Decisions/memory/allow' ./internal/limiter
+func BenchmarkDecisions(b *testing.B) {
+	backends := benchBackends(b)
+	for _, backendName := range sortedNames(backends) {
+		for _, decisionName := range sortedNames(benchDecisions) {
+			for _, cardinality := range benchCardinalities {
+				newStorage, decide, cardinality := backends[backendName], benchDecisions[decisionName], cardinality
+				b.Run(fmt.Sprintf("%s/%s/keys=%d", backendName, decisionName, cardinality), func(b *testing.B) {
+					clients := benchClients(cardinality)
+					rl := newBenchLimiter(newStorage(b), clients)
+					defer rl.Close()
+					ctx := context.Background()
+
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						decide(ctx, rl, clients, i)
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkDecisionsParallel measures Allow from GOMAXPROCS goroutines, where
+// a single hot key contends on one counter
+func BenchmarkDecisionsParallel(b *testing.B) {
+	backends := benchBackends(b)
+	for _, backendName := range sortedNames(backends) {
+		for _, cardinality := range benchCardinalities {
+			newStorage, cardinality := backends[backendName], cardinality
+			b.Run(fmt.Sprintf("%s/allow/keys=%d", backendName, cardinality), func(b *testing.B) {
+				clients := benchClients(cardinality)
+				rl := NewRateLimiter(newStorage(b), benchLimit, 300)
+				defer rl.Close()
+				ctx := context.Background()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := 0
+					for pb.Next() {
+						rl.Allow(ctx, clients[i%len(clients)], "")
+						i++
+					}
+				})
+			})
+		}
+	}
+}
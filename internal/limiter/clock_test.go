@@ -0,0 +1,154 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+var clockStart = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// TestWindowResetsWithClock tests that the counter starts over when the window expires
+func TestWindowResetsWithClock(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	limiter := NewRateLimiter(NewMockStorageWithClock(now), 2, 60)
+	limiter.SetClock(now)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow(ctx, "192.168.1.1", ""); !allowed {
+			t.Fatalf("Request %d should have been allowed", i+1)
+		}
+	}
+	now.Advance(59 * time.Second)
+	if allowed, _ := limiter.Allow(ctx, "192.168.1.1", ""); allowed {
+		t.Fatal("Request before the window expires should be denied")
+	}
+
+	now.Advance(time.Second)
+	if allowed, _ := limiter.Allow(ctx, "192.168.1.1", ""); !allowed {
+		t.Fatal("Request after the window expires should be allowed")
+	}
+	if decision, _ := limiter.Decide(ctx, "192.168.1.1", ""); decision.Remaining != 0 || decision.Reset != 60*time.Second {
+		t.Fatalf("The new window should start at the first request, got %+v", decision)
+	}
+}
+
+// TestBlockExpiresWithClock tests that a blocked client reports less time
+// left as the clock moves and is allowed again once the block expires
+func TestBlockExpiresWithClock(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	limiter := NewRateLimiter(strategy.NewMemoryStorageWithClock(now), 5, 300)
+	limiter.ConfigureToken("abc123", 1, 120)
+	limiter.SetClock(now)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "192.168.1.1", "abc123")
+	decision, _ := limiter.Decide(ctx, "192.168.1.1", "abc123")
+	if decision.Allowed || decision.Reset != 120*time.Second {
+		t.Fatalf("Token should be blocked for 120s, got %+v", decision)
+	}
+
+	// The time left decays with the clock, it is not extended by denied requests
+	for _, elapsed := range []time.Duration{30 * time.Second, 60 * time.Second, 29 * time.Second} {
+		now.Advance(elapsed)
+		before := decision.Reset
+		decision, _ = limiter.Decide(ctx, "192.168.1.1", "abc123")
+		if decision.Allowed || decision.Reset != before-elapsed {
+			t.Fatalf("Expected block with %v left, got %+v", before-elapsed, decision)
+		}
+	}
+
+	now.Advance(time.Second)
+	if decision, _ := limiter.Decide(ctx, "192.168.1.1", "abc123"); !decision.Allowed {
+		t.Fatalf("Token should be allowed once the block expires, got %+v", decision)
+	}
+}
+
+// TestQuotaWindowRollsOverWithClock tests that quota windows start over at the calendar boundary
+func TestQuotaWindowRollsOverWithClock(t *testing.T) {
+	now := clock.NewFake(clockStart.Add(58 * time.Second))
+	limiter := NewRateLimiter(strategy.NewMemoryStorageWithClock(now), 5, 300)
+	limiter.ConfigureQuotas("acme", QuotaWindow{Period: PeriodMinute, Limit: 1}, QuotaWindow{Period: PeriodHour, Limit: 2})
+	limiter.SetClock(now)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	limiter.Allow(ctx, "", "acme")
+	now.Advance(time.Second)
+	if allowed, _ := limiter.Allow(ctx, "", "acme"); allowed {
+		t.Fatal("Minute quota should be used up")
+	}
+
+	now.Advance(time.Second)
+	if allowed, _ := limiter.Allow(ctx, "", "acme"); !allowed {
+		t.Fatal("A new minute should start a new window")
+	}
+	usage, _ := limiter.QuotaUsage(ctx, "acme")
+	if usage[0].Used != 1 || !usage[0].ResetsAt.Equal(clockStart.Add(2*time.Minute)) || usage[1].Used != 2 {
+		t.Fatalf("Unexpected usage %+v", usage)
+	}
+}
+
+// TestReservationDelayWithClock tests that the reservation delay follows the clock
+func TestReservationDelayWithClock(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	limiter := NewRateLimiter(strategy.NewMemoryStorageWithClock(now), 5, 300)
+	limiter.ConfigurePolicy("export", 1, 60)
+	limiter.SetClock(now)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	limiter.Check(ctx, "export", []string{"tenant:a"}, 1)
+	reservation, err := limiter.Reserve(ctx, "export", []string{"tenant:a"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer reservation.Cancel(ctx)
+
+	now.Advance(45 * time.Second)
+	if delay := reservation.Delay(); delay != 15*time.Second {
+		t.Fatalf("Expected 15s left, got %v", delay)
+	}
+	now.Advance(15 * time.Second)
+	if delay := reservation.Delay(); delay != 0 {
+		t.Fatalf("Expected no delay once the window resets, got %v", delay)
+	}
+}
+
+// TestCancelDoesNotRefundNewWindow tests that units are only returned to the window they were consumed in
+func TestCancelDoesNotRefundNewWindow(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	storage := strategy.NewMemoryStorageWithClock(now)
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigurePolicy("export", 2, 60)
+	limiter.SetClock(now)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	reservation, err := limiter.Reserve(ctx, "export", []string{"tenant", "a"}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The window resets and another caller consumes the new one
+	now.Advance(61 * time.Second)
+	limiter.Check(ctx, "export", []string{"tenant", "a"}, 2)
+
+	if err := reservation.Cancel(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if counter, _ := storage.GetCounter(ctx, "limiter:policy:export:{tenant:a}"); counter != 2 {
+		t.Fatalf("Units must not be returned to a later window, counter is %d", counter)
+	}
+}
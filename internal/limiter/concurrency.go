@@ -0,0 +1,241 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// ConcurrencyLimiter caps the number of in-flight requests per client. Slots
+// are leases in the storage: they are renewed while the request runs and
+// expire on their own if the instance holding them dies.
+type ConcurrencyLimiter struct {
+	storage      strategy.StorageStrategy
+	defaultLimit int
+	lease        time.Duration
+	timeout      time.Duration
+	failureMode  FailureMode
+	fallback     strategy.StorageStrategy
+	outageLog    throttledLog
+
+	// tokenLimits can be configured while requests are served
+	mu          sync.Mutex
+	tokenLimits map[string]int
+}
+
+// minLease is the shortest lease accepted, leases are renewed every third of it
+const minLease = time.Millisecond
+
+// NewConcurrencyLimiter creates the limiter. The storage must implement
+// strategy.LeaseStorage; a defaultLimit of 0 leaves clients unlimited unless
+// a route or token limit applies.
+func NewConcurrencyLimiter(storage strategy.StorageStrategy, defaultLimit int, lease time.Duration) (*ConcurrencyLimiter, error) {
+	if lease < minLease {
+		return nil, fmt.Errorf("invalid concurrency lease %v (minimum %v)", lease, minLease)
+	}
+	if !strategy.SupportsLeases(storage) {
+		return nil, errors.New("concurrency limiter: storage does not support leases")
+	}
+
+	return &ConcurrencyLimiter{
+		storage:      storage,
+		defaultLimit: defaultLimit,
+		lease:        lease,
+		tokenLimits:  make(map[string]int),
+		failureMode:  FailClosed,
+	}, nil
+}
+
+// ConfigureToken sets the in-flight limit for a token, overriding route and default limits
+func (cl *ConcurrencyLimiter) ConfigureToken(token string, limit int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.tokenLimits[token] = limit
+}
+
+// tokenLimit returns the in-flight limit configured for a token
+func (cl *ConcurrencyLimiter) tokenLimit(token string) (int, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	limit, exists := cl.tokenLimits[token]
+	return limit, exists
+}
+
+// SetFailureMode sets how requests are decided when the storage is unavailable.
+// The fallback storage is only used with FailLocal.
+func (cl *ConcurrencyLimiter) SetFailureMode(mode FailureMode, fallback strategy.StorageStrategy) {
+	cl.failureMode = mode
+	cl.fallback = fallback
+}
+
+// SetDecisionTimeout bounds the time each storage call on a slot (acquire,
+// renew and release) may take, like RateLimiter.SetDecisionTimeout. An
+// acquire that runs out of time is decided by the failure mode.
+func (cl *ConcurrencyLimiter) SetDecisionTimeout(timeout time.Duration) {
+	cl.timeout = timeout
+}
+
+// Close closes the fallback storage. The storage holding the leases is
+// usually shared with the rate limiter and is left to its owner.
+func (cl *ConcurrencyLimiter) Close() error {
+	if cl.fallback == nil {
+		return nil
+	}
+	return cl.fallback.Close()
+}
+
+// Slot is an acquired in-flight slot, it must be released when the request completes
+type Slot struct {
+	storage strategy.LeaseStorage
+	key     string
+	id      string
+	timeout time.Duration
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// Acquire takes a slot for the client within scope (e.g. a route prefix).
+// routeLimit applies when the token has no limit of its own; 0 falls back to
+// the default limit. It returns a nil slot when the client is at its limit, and
+// a no-op slot when no limit applies.
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context, scope string, ip string, token string, routeLimit int) (*Slot, error) {
+	limit := cl.defaultLimit
+	if routeLimit > 0 {
+		limit = routeLimit
+	}
+	id := "ip:" + ip
+	if token != "" {
+		id = "token:" + token
+		if tokenLimit, exists := cl.tokenLimit(token); exists {
+			limit = tokenLimit
+		}
+	}
+	if limit <= 0 {
+		return &Slot{}, nil
+	}
+
+	key := clientKey("concurrency:"+scope, id)
+	ctx, cancel := withTimeout(ctx, cl.timeout)
+	defer cancel()
+
+	slot, err := cl.acquire(ctx, cl.storage, key, limit)
+	if err == nil {
+		cl.outageLog.Recovered("concurrency limiter: storage recovered")
+		return slot, nil
+	}
+
+	switch cl.failureMode {
+	case FailOpen:
+		cl.outageLog.Printf("concurrency limiter: storage unavailable, allowing %s: %v", key, err)
+		return &Slot{}, nil
+	case FailLocal:
+		if cl.fallback != nil {
+			cl.outageLog.Printf("concurrency limiter: storage unavailable, using local limits for %s: %v", key, err)
+			// The local storage answers at once, even when the time is spent
+			if slot, fallbackErr := cl.acquire(context.WithoutCancel(ctx), cl.fallback, key, limit); fallbackErr == nil {
+				return slot, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+}
+
+// acquire takes a lease in the given storage and keeps renewing it until released
+func (cl *ConcurrencyLimiter) acquire(ctx context.Context, storage strategy.StorageStrategy, key string, limit int) (*Slot, error) {
+	leases, ok := storage.(strategy.LeaseStorage)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+
+	id, err := newLeaseID()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := leases.AcquireLease(ctx, key, id, limit, cl.lease)
+	if err != nil || !acquired {
+		return nil, err
+	}
+
+	slot := &Slot{storage: leases, key: key, id: id, timeout: cl.timeout, stop: make(chan struct{})}
+	go slot.renew(cl.lease)
+	return slot, nil
+}
+
+// renew extends the lease while the request runs
+func (s *Slot) renew(lease time.Duration) {
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	timeout := lease / 3
+	if s.timeout > 0 {
+		timeout = min(timeout, s.timeout)
+	}
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := s.storage.RenewLease(ctx, s.key, s.id, lease); err != nil {
+				log.Printf("concurrency limiter: failed to renew lease for %s: %v", s.key, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Release frees the slot. It is safe to call more than once and on no-op slots.
+func (s *Slot) Release(ctx context.Context) error {
+	if s.storage == nil {
+		return nil
+	}
+
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		ctx, cancel := withTimeout(ctx, s.timeout)
+		defer cancel()
+		err = s.storage.ReleaseLease(ctx, s.key, s.id)
+	})
+	return err
+}
+
+// ParseConcurrencyTokens parses a comma separated list of token=limit entries,
+// e.g. "token123=2,premium-token=20"
+func ParseConcurrencyTokens(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		token, limit, found := strings.Cut(entry, "=")
+		parsed, err := strconv.Atoi(limit)
+		if !found || token == "" || err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid concurrency token %q (expected token=limit)", entry)
+		}
+		limits[token] = parsed
+	}
+	return limits, nil
+}
+
+func newLeaseID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,200 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// newConcurrencyLimiter creates a concurrency limiter, failing the test on error
+func newConcurrencyLimiter(t *testing.T, storage strategy.StorageStrategy, defaultLimit int, lease time.Duration) *ConcurrencyLimiter {
+	t.Helper()
+	cl, err := NewConcurrencyLimiter(storage, defaultLimit, lease)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return cl
+}
+
+// TestConcurrencyLimit tests that slots are limited per client and freed on release
+func TestConcurrencyLimit(t *testing.T) {
+	cl := newConcurrencyLimiter(t, strategy.NewMemoryStorage(), 2, time.Minute)
+	ctx := context.Background()
+
+	var slots []*Slot
+	for i := 0; i < 2; i++ {
+		slot, err := cl.Acquire(ctx, "*", "192.168.1.1", "", 0)
+		if err != nil || slot == nil {
+			t.Fatalf("Slot %d should be acquired, got %v, %v", i+1, slot, err)
+		}
+		slots = append(slots, slot)
+	}
+
+	if slot, _ := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); slot != nil {
+		t.Fatal("Third concurrent slot should be refused")
+	}
+	if slot, _ := cl.Acquire(ctx, "*", "192.168.1.2", "", 0); slot == nil {
+		t.Fatal("Other clients should have their own slots")
+	}
+
+	if err := slots[0].Release(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := slots[0].Release(ctx); err != nil {
+		t.Fatalf("Releasing twice should be a no-op, got %v", err)
+	}
+	if slot, _ := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); slot == nil {
+		t.Fatal("Released slot should be available again")
+	}
+}
+
+// TestConcurrencyLimitPrecedence tests token, route and default limits
+func TestConcurrencyLimitPrecedence(t *testing.T) {
+	cl := newConcurrencyLimiter(t, strategy.NewMemoryStorage(), 0, time.Minute)
+	cl.ConfigureToken("vip", 3)
+	ctx := context.Background()
+
+	// No default limit: requests outside route rules are not limited
+	for i := 0; i < 5; i++ {
+		if slot, _ := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); slot == nil {
+			t.Fatal("Requests without a limit should always acquire a slot")
+		}
+	}
+
+	if slot, _ := cl.Acquire(ctx, "/api/reports/", "192.168.1.1", "", 1); slot == nil {
+		t.Fatal("First slot on the route should be acquired")
+	}
+	if slot, _ := cl.Acquire(ctx, "/api/reports/", "192.168.1.1", "", 1); slot != nil {
+		t.Fatal("Route limit should refuse the second slot")
+	}
+
+	for i := 0; i < 3; i++ {
+		if slot, _ := cl.Acquire(ctx, "/api/reports/", "192.168.1.1", "vip", 1); slot == nil {
+			t.Fatalf("Token limit should override the route limit, slot %d refused", i+1)
+		}
+	}
+}
+
+// TestConcurrencyLeaseExpires tests that slots held by a crashed instance expire
+func TestConcurrencyLeaseExpires(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	ctx := context.Background()
+
+	// Simulate a crashed instance: a lease that is never renewed nor released
+	if _, err := storage.AcquireLease(ctx, "limiter:concurrency:*:{ip:192.168.1.1}", "crashed", 1, 100*time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cl := newConcurrencyLimiter(t, storage, 1, 100*time.Millisecond)
+	if slot, _ := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); slot != nil {
+		t.Fatal("Slot should be held by the crashed instance")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	slot, err := cl.Acquire(ctx, "*", "192.168.1.1", "", 0)
+	if err != nil || slot == nil {
+		t.Fatalf("Expired lease should free the slot, got %v, %v", slot, err)
+	}
+	defer slot.Release(ctx)
+
+	// A held slot is renewed and outlives its lease
+	time.Sleep(250 * time.Millisecond)
+	if other, _ := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); other != nil {
+		t.Fatal("Held slot should be renewed while in use")
+	}
+}
+
+// TestParseConcurrencyTokens tests parsing of the per-token concurrency limits
+func TestParseConcurrencyTokens(t *testing.T) {
+	limits, err := ParseConcurrencyTokens("token123=2, premium-token=20")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(limits) != 2 || limits["token123"] != 2 || limits["premium-token"] != 20 {
+		t.Fatalf("Unexpected limits %v", limits)
+	}
+
+	for _, invalid := range []string{"token123", "=2", "token123=-1", "token123=x"} {
+		if _, err := ParseConcurrencyTokens(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestNewConcurrencyLimiterValidates tests that leases too short to renew and
+// storages without leases are rejected when the limiter is created
+func TestNewConcurrencyLimiterValidates(t *testing.T) {
+	for _, lease := range []time.Duration{0, -time.Second, time.Nanosecond} {
+		if _, err := NewConcurrencyLimiter(strategy.NewMemoryStorage(), 1, lease); err == nil {
+			t.Fatalf("Expected error for lease %v", lease)
+		}
+	}
+
+	for name, storage := range map[string]strategy.StorageStrategy{
+		"plain":   plainStorage{strategy.NewMemoryStorage()},
+		"wrapped": strategy.NewCircuitBreakerStorage(plainStorage{strategy.NewMemoryStorage()}, 5, 30),
+	} {
+		if _, err := NewConcurrencyLimiter(storage, 1, time.Minute); err == nil {
+			t.Fatalf("%s: expected error for a storage without leases", name)
+		}
+	}
+	if _, err := NewConcurrencyLimiter(strategy.NewCircuitBreakerStorage(strategy.NewMemoryStorage(), 5, 30), 1, time.Minute); err != nil {
+		t.Fatalf("Wrapped lease storage should be accepted, got %v", err)
+	}
+}
+
+// TestConfigureTokenWhileServing tests that token limits can change while slots are acquired; run it with -race
+func TestConfigureTokenWhileServing(t *testing.T) {
+	cl := newConcurrencyLimiter(t, strategy.NewMemoryStorage(), 100, time.Minute)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cl.ConfigureToken(fmt.Sprintf("token-%d", j%5), 10+i)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if slot, err := cl.Acquire(ctx, "*", "192.168.1.1", fmt.Sprintf("token-%d", j%5), 0); err == nil && slot != nil {
+					slot.Release(ctx)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestConcurrencyFailOpenLogsOncePerOutage tests that allowed requests during an outage do not flood the log
+func TestConcurrencyFailOpenLogsOncePerOutage(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{ErrorRate: 1})
+	cl := newConcurrencyLimiter(t, storage, 2, time.Minute)
+	cl.SetFailureMode(FailOpen, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		if slot, err := cl.Acquire(ctx, "*", "192.168.1.1", "", 0); slot == nil || err != nil {
+			t.Fatalf("Expected the request to be allowed, got %v, %v", slot, err)
+		}
+	}
+
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 1 {
+		t.Fatalf("Expected one log line, got:\n%s", output.String())
+	}
+}
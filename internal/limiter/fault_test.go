@@ -0,0 +1,151 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// faultClients returns the IPs used by the fault tests and their counter keys
+func faultClients(n int) ([]string, []string) {
+	ips := make([]string, n)
+	keys := make([]string, n)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.0.%d", i)
+		keys[i] = clientKey("ip", ips[i])
+	}
+	return ips, keys
+}
+
+// keysWithoutTTL returns the keys that exist in the storage with no expiration
+func keysWithoutTTL(t *testing.T, storage *strategy.MemoryStorage, keys []string) []string {
+	t.Helper()
+	ctx := context.Background()
+	var missing []string
+	for _, key := range keys {
+		exists, err := storage.Exists(ctx, key)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ttl, _ := storage.TTL(ctx, key); exists && ttl <= 0 {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// TestFailedExpirationKeepsCounter tests that a counter whose expiration
+// failed is kept, so the client is not let through, and gets its expiration
+// once it reaches the limit
+func TestFailedExpirationKeepsCounter(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	memory := strategy.NewMemoryStorageWithClock(now)
+	storage := strategy.NewFaultStorage(memory, strategy.FaultConfig{ExpireErrorRate: 1, SplitConsume: true})
+	limiter := NewRateLimiter(storage, 5, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	key := clientKey("ip", "10.0.0.1")
+	if _, err := limiter.Allow(ctx, "10.0.0.1", ""); err == nil {
+		t.Fatal("Expected the expiration to fail")
+	}
+	if counter, _ := memory.GetCounter(ctx, key); counter != 1 {
+		t.Fatalf("Counter should be kept, got %d", counter)
+	}
+
+	storage.SetConfig(strategy.FaultConfig{SplitConsume: true})
+	for i := 0; i < 4; i++ {
+		if allowed, err := limiter.Allow(ctx, "10.0.0.1", ""); err != nil || !allowed {
+			t.Fatalf("Request %d should be allowed, got %v, %v", i+2, allowed, err)
+		}
+	}
+	if allowed, err := limiter.Allow(ctx, "10.0.0.1", ""); err != nil || allowed {
+		t.Fatalf("Client over the limit should be denied, got %v, %v", allowed, err)
+	}
+	if missing := keysWithoutTTL(t, memory, []string{key}); len(missing) > 0 {
+		t.Fatalf("Keys left without TTL: %v", missing)
+	}
+
+	now.Advance(61 * time.Second)
+	if allowed, err := limiter.Allow(ctx, "10.0.0.1", ""); err != nil || !allowed {
+		t.Fatalf("Client should be allowed after the block duration, got %v, %v", allowed, err)
+	}
+}
+
+// TestNoPermanentBlockUnderFaults tests that, whatever fails along the way,
+// every key gets an expiration once the storage recovers and no client stays
+// blocked past its block duration
+func TestNoPermanentBlockUnderFaults(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	memory := strategy.NewMemoryStorageWithClock(now)
+	storage := strategy.NewFaultStorage(memory, strategy.FaultConfig{
+		ErrorRate:       0.2,
+		ExpireErrorRate: 0.3,
+		LostReplyRate:   0.2,
+		SplitConsume:    true,
+		Seed:            2,
+	})
+	limiter := NewRateLimiter(storage, 5, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	ips, keys := faultClients(20)
+
+	for i := 0; i < 2000; i++ {
+		limiter.Allow(ctx, ips[i%len(ips)], "")
+		now.Advance(50 * time.Millisecond)
+	}
+
+	// A key without TTL is repaired when its client reaches the limit
+	storage.SetConfig(strategy.FaultConfig{SplitConsume: true})
+	for _, ip := range ips {
+		for i := 0; i < 6; i++ {
+			if _, err := limiter.Allow(ctx, ip, ""); err != nil {
+				t.Fatalf("Unexpected error once faults stop: %v", err)
+			}
+		}
+	}
+	if missing := keysWithoutTTL(t, memory, keys); len(missing) > 0 {
+		t.Fatalf("Keys left without TTL: %v", missing)
+	}
+
+	now.Advance(61 * time.Second)
+	for _, ip := range ips {
+		if allowed, err := limiter.Allow(ctx, ip, ""); err != nil || !allowed {
+			t.Fatalf("Client %s should be allowed after the block duration, got %v, %v", ip, allowed, err)
+		}
+	}
+}
+
+// TestFailedBlockExpirationIsRepaired tests that a failed expiration on Block
+// keeps the client blocked, but not for good
+func TestFailedBlockExpirationIsRepaired(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	memory := strategy.NewMemoryStorageWithClock(now)
+	storage := strategy.NewFaultStorage(memory, strategy.FaultConfig{ExpireErrorRate: 1, SplitConsume: true})
+	limiter := NewRateLimiter(storage, 5, 60)
+	limiter.ConfigurePolicy("upstream", 10, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	if err := limiter.Block(ctx, "upstream", []string{"acme"}, time.Minute); err == nil {
+		t.Fatal("Expected the block to fail")
+	}
+
+	storage.SetConfig(strategy.FaultConfig{SplitConsume: true})
+	if decision, err := limiter.Check(ctx, "upstream", []string{"acme"}, 1); err != nil || decision.Allowed {
+		t.Fatalf("Key should stay blocked, got %+v, %v", decision, err)
+	}
+	if missing := keysWithoutTTL(t, memory, []string{clientKey("policy:upstream", "acme")}); len(missing) > 0 {
+		t.Fatalf("Keys left without TTL: %v", missing)
+	}
+
+	now.Advance(61 * time.Second)
+	if decision, err := limiter.Check(ctx, "upstream", []string{"acme"}, 1); err != nil || !decision.Allowed {
+		t.Fatalf("Key should be allowed after the block, got %+v, %v", decision, err)
+	}
+}
@@ -2,11 +2,45 @@ package limiter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
 
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
 	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
 )
 
+// ErrStorageUnavailable is returned when a decision cannot be made because the storage failed
+var ErrStorageUnavailable = errors.New("rate limiter storage unavailable")
+
+// ErrStorageTimeout is returned along with ErrStorageUnavailable when the
+// storage did not answer within the decision timeout or the request deadline
+var ErrStorageTimeout = errors.New("rate limiter storage timed out")
+
+// FailureMode defines how the limiter decides when the storage is unavailable
+type FailureMode string
+
+const (
+	// FailClosed denies requests while the storage is unavailable
+	FailClosed FailureMode = "closed"
+	// FailOpen allows requests while the storage is unavailable
+	FailOpen FailureMode = "open"
+	// FailLocal falls back to a local storage with per-instance limits
+	FailLocal FailureMode = "local"
+)
+
+// ParseFailureMode converts a configuration value into a FailureMode
+func ParseFailureMode(value string) (FailureMode, error) {
+	switch mode := FailureMode(value); mode {
+	case FailClosed, FailOpen, FailLocal:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid failure mode %q (expected closed, open or local)", value)
+	}
+}
+
 // RateLimiter handles the rate limiting logic separated from middleware
 type RateLimiter struct {
 	storage              strategy.StorageStrategy
@@ -14,6 +48,30 @@ type RateLimiter struct {
 	defaultBlockDuration int
 	tokenLimits          map[string]int
 	tokenBlockDurations  map[string]int
+	policies             map[string]Policy
+	quotas               map[string][]QuotaWindow
+	quotaLocation        *time.Location
+	observers            []Observer
+	shadow               shadowRegistry
+	failureMode          FailureMode
+	fallback             strategy.StorageStrategy
+	timeout              time.Duration
+	failures             storageFailures
+	outageLog            throttledLog
+	clock                clock.Clock
+}
+
+// StorageFailures counts the decisions the storage failed to answer
+type StorageFailures struct {
+	// Timeouts ran out of time, see SetDecisionTimeout
+	Timeouts int64
+	// Errors failed for any other reason
+	Errors int64
+}
+
+type storageFailures struct {
+	timeouts atomic.Int64
+	errors   atomic.Int64
 }
 
 // NewRateLimiter creates a new rate limiter instance
@@ -28,6 +86,11 @@ func NewRateLimiter(
 		defaultBlockDuration: defaultBlockDuration,
 		tokenLimits:          make(map[string]int),
 		tokenBlockDurations:  make(map[string]int),
+		policies:             make(map[string]Policy),
+		quotas:               make(map[string][]QuotaWindow),
+		quotaLocation:        time.UTC,
+		failureMode:          FailClosed,
+		clock:                clock.Real,
 	}
 }
 
@@ -37,25 +100,114 @@ func (rl *RateLimiter) ConfigureToken(token string, limit int, blockDuration int
 	rl.tokenBlockDurations[token] = blockDuration
 }
 
+// clientKey builds the storage key for a client. The identifier is wrapped in a
+// Redis Cluster hash tag so every key of the same client lands on the same slot.
+func clientKey(kind string, id string) string {
+	return fmt.Sprintf("limiter:%s:{%s}", kind, id)
+}
+
+// SetClock sets the clock used for quota windows, reservations and observer
+// events. Counter and block expiry follow the storage, so tests moving a fake
+// clock should also give it to the memory storage.
+func (rl *RateLimiter) SetClock(clock clock.Clock) {
+	rl.clock = clock
+}
+
+// SetFailureMode sets how requests are decided when the storage is unavailable.
+// The fallback storage is only used with FailLocal.
+func (rl *RateLimiter) SetFailureMode(mode FailureMode, fallback strategy.StorageStrategy) {
+	rl.failureMode = mode
+	rl.fallback = fallback
+}
+
+// SetDecisionTimeout bounds the time each decision may spend on the storage,
+// e.g. 20ms, so a slow storage cannot stall requests. A decision that runs
+// out of time is decided by the failure mode. Zero only bounds decisions by
+// the request context.
+func (rl *RateLimiter) SetDecisionTimeout(timeout time.Duration) {
+	rl.timeout = timeout
+}
+
+// StorageFailures returns the number of decisions the storage failed to
+// answer since the limiter was created, timeouts counted apart
+func (rl *RateLimiter) StorageFailures() StorageFailures {
+	return StorageFailures{
+		Timeouts: rl.failures.timeouts.Load(),
+		Errors:   rl.failures.errors.Load(),
+	}
+}
+
+// withTimeout applies the decision timeout to ctx
+func (rl *RateLimiter) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, rl.timeout)
+}
+
+// withTimeout bounds ctx by timeout, zero leaves it as is
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // Allow checks if a request should be allowed based on IP or token
 func (rl *RateLimiter) Allow(ctx context.Context, ip string, token string) (bool, error) {
-	// Token takes precedence over IP
-	if token != "" {
-		return rl.checkToken(ctx, token)
+	decision, err := rl.decideClient(ctx, ip, token, 1, false)
+	return decision.Allowed, err
+}
+
+// AllowN is like Allow for a request that costs n units. The units are only
+// consumed when all of them fit in the window; a cost of 0 is allowed unless
+// the client is already blocked.
+func (rl *RateLimiter) AllowN(ctx context.Context, ip string, token string, n int) (bool, error) {
+	if n < 0 {
+		return false, fmt.Errorf("invalid cost %d", n)
 	}
 
-	return rl.checkIP(ctx, ip)
+	decision, err := rl.decideClient(ctx, ip, token, n, false)
+	return decision.Allowed, err
 }
 
-// checkIP checks if the request from the IP should be allowed
-func (rl *RateLimiter) checkIP(ctx context.Context, ip string) (bool, error) {
-	key := fmt.Sprintf("limiter:ip:%s", ip)
-	return rl.check(ctx, key, rl.defaultLimit, rl.defaultBlockDuration)
+// Decide is like Allow but reports the full decision, including the time
+// until the window resets, for callers that expose rate limit headers
+func (rl *RateLimiter) Decide(ctx context.Context, ip string, token string) (Decision, error) {
+	return rl.decideClient(ctx, ip, token, 1, true)
 }
 
-// checkToken checks if the request with the token should be allowed
-func (rl *RateLimiter) checkToken(ctx context.Context, token string) (bool, error) {
-	key := fmt.Sprintf("limiter:token:%s", token)
+// decideClient consumes cost units for the client, from the token quotas when
+// it has any, and notifies the observers. withReset also reports the time
+// until the window resets, which costs an extra storage call.
+func (rl *RateLimiter) decideClient(ctx context.Context, ip string, token string, cost int, withReset bool) (Decision, error) {
+	ctx, cancel := rl.withTimeout(ctx)
+	defer cancel()
+
+	event := Event{IP: ip, Token: token, Cost: cost, Time: rl.clock.Now()}
+
+	var err error
+	if windows, exists := rl.tokenQuotas(token); exists {
+		event.Decision, event.Quotas, err = rl.decideQuotas(ctx, token, windows, cost, event.Time)
+	} else {
+		key, limit, blockDuration := rl.clientLimits(ip, token)
+		event.Key = key
+		event.Decision, err = rl.decide(ctx, key, limit, blockDuration, cost)
+		if err == nil && withReset {
+			event.Decision.Reset = rl.resetAfter(ctx, key, blockDuration)
+		}
+	}
+	if err != nil {
+		return event.Decision, err
+	}
+
+	rl.notify(event)
+	return event.Decision, nil
+}
+
+// clientLimits returns the storage key, limit and block duration for a client.
+// Token takes precedence over IP.
+func (rl *RateLimiter) clientLimits(ip string, token string) (string, int, int) {
+	if token == "" {
+		return clientKey("ip", ip), rl.defaultLimit, rl.defaultBlockDuration
+	}
 
 	limit := rl.defaultLimit
 	blockDuration := rl.defaultBlockDuration
@@ -68,55 +220,217 @@ func (rl *RateLimiter) checkToken(ctx context.Context, token string) (bool, erro
 		blockDuration = customDuration
 	}
 
-	return rl.check(ctx, key, limit, blockDuration)
+	return clientKey("token", token), limit, blockDuration
 }
 
-// check performs the actual rate limit check
-func (rl *RateLimiter) check(ctx context.Context, key string, limit int, blockDuration int) (bool, error) {
+// tokenQuotas returns the quota windows of a token, if it has any
+func (rl *RateLimiter) tokenQuotas(token string) ([]QuotaWindow, bool) {
+	if token == "" {
+		return nil, false
+	}
+	windows, exists := rl.quotas[token]
+	return windows, exists && len(windows) > 0
+}
+
+// decide consumes cost units from the key, applying the failure mode on storage errors
+func (rl *RateLimiter) decide(ctx context.Context, key string, limit int, blockDuration int, cost int) (Decision, error) {
+	return rl.withFailureMode(ctx, key, limit, func(ctx context.Context, storage strategy.StorageStrategy) (Decision, error) {
+		return rl.checkStorage(ctx, storage, key, limit, blockDuration, cost)
+	})
+}
+
+// withFailureMode runs check against the storage and, when it fails, decides
+// according to the failure mode. Timeouts are logged and counted apart from
+// other storage errors.
+func (rl *RateLimiter) withFailureMode(ctx context.Context, key string, limit int, check func(context.Context, strategy.StorageStrategy) (Decision, error)) (Decision, error) {
+	decision, err := check(ctx, rl.storage)
+	if err == nil {
+		rl.outageLog.Recovered("rate limiter: storage recovered")
+		return decision, nil
+	}
+
+	failure := "unavailable"
+	if isTimeout(err) {
+		failure = "timed out"
+		rl.failures.timeouts.Add(1)
+		err = fmt.Errorf("%w: %v", ErrStorageTimeout, err)
+	} else {
+		rl.failures.errors.Add(1)
+	}
+
+	switch rl.failureMode {
+	case FailOpen:
+		rl.outageLog.Printf("rate limiter: storage %s, allowing %s: %v", failure, key, err)
+		return Decision{Allowed: true, Limit: limit, Remaining: limit}, nil
+	case FailLocal:
+		if rl.fallback != nil {
+			rl.outageLog.Printf("rate limiter: storage %s, using local limits for %s: %v", failure, key, err)
+			// The local storage answers at once, even when the time is spent
+			if decision, fallbackErr := check(context.WithoutCancel(ctx), rl.fallback); fallbackErr == nil {
+				return decision, nil
+			}
+		}
+	default:
+		// Outages show up in readiness checks, a slow storage only here
+		if errors.Is(err, ErrStorageTimeout) {
+			rl.outageLog.Printf("rate limiter: storage %s, denying %s: %v", failure, key, err)
+		}
+	}
+
+	return Decision{Limit: limit}, fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+}
+
+// isTimeout reports whether a storage call failed for lack of time, either
+// the decision timeout or a network timeout, rather than failing outright
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// checkStorage performs the actual rate limit check against the given storage
+func (rl *RateLimiter) checkStorage(ctx context.Context, storage strategy.StorageStrategy, key string, limit int, blockDuration int, cost int) (Decision, error) {
+	decision := Decision{Limit: limit}
+
+	// Check and consume in one step when the storage supports it
+	if consumer, ok := storage.(strategy.AtomicConsumer); ok {
+		counter, consumed, err := consumer.Consume(ctx, key, cost, limit, blockDuration)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			if err != nil {
+				return decision, err
+			}
+			if recorder, ok := storage.(strategy.BlockRecorder); ok && !consumed && counter >= limit {
+				recorder.RecordBlock(ctx, key, counter)
+			}
+			decision.Allowed = consumed
+			decision.Remaining = remaining(limit, counter)
+			return decision, nil
+		}
+	}
+
 	// Get current counter
-	counter, err := rl.storage.GetCounter(ctx, key)
+	counter, err := storage.GetCounter(ctx, key)
 	if err != nil {
-		return false, err
+		return decision, err
 	}
 
-	// If blocked, deny the request
-	if counter >= limit {
-		return false, nil
+	// If blocked, or the cost does not fit in what is left, deny the request
+	if counter >= limit || counter+cost > limit {
+		if counter >= limit {
+			// Only a key at its limit can block the client for good, so the
+			// expiration is checked here rather than on every request
+			if err := ensureExpiration(ctx, storage, key, blockDuration); err != nil {
+				return decision, err
+			}
+			if recorder, ok := storage.(strategy.BlockRecorder); ok {
+				recorder.RecordBlock(ctx, key, counter)
+			}
+		}
+		decision.Remaining = remaining(limit, counter)
+		return decision, nil
+	}
+
+	// Zero-cost requests only need the current state
+	if cost == 0 {
+		decision.Allowed = true
+		decision.Remaining = remaining(limit, counter)
+		return decision, nil
 	}
 
 	// Increment counter
-	newCounter, err := rl.storage.IncrementCounter(ctx, key)
+	newCounter, err := incrementBy(ctx, storage, key, cost)
 	if err != nil {
-		return false, err
+		return decision, err
 	}
 
-	// Set expiration only on first request in the window
-	if newCounter == 1 {
-		err = rl.storage.SetExpiration(ctx, key, blockDuration)
-		if err != nil {
-			return false, err
+	// Set expiration only on first request in the window. If it fails the
+	// counter is kept, the key gets its expiration once it reaches the limit.
+	if newCounter == cost {
+		if err := storage.SetExpiration(ctx, key, blockDuration); err != nil {
+			return decision, err
 		}
 	}
 
-	return true, nil
+	decision.Allowed = true
+	decision.Remaining = remaining(limit, newCounter)
+	return decision, nil
+}
+
+// incrementBy adds cost to the counter, in a single call when the storage
+// supports it. Wrappers such as the circuit breaker always implement
+// BatchIncrementer and report ErrUnsupported when the storage they wrap does not.
+func incrementBy(ctx context.Context, storage strategy.StorageStrategy, key string, cost int) (int, error) {
+	if incrementer, ok := storage.(strategy.BatchIncrementer); ok && cost != 1 {
+		counter, err := incrementer.IncrementCounterBy(ctx, key, cost)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return counter, err
+		}
+	}
+
+	var counter int
+	var err error
+	for i := 0; i < cost; i++ {
+		if counter, err = storage.IncrementCounter(ctx, key); err != nil {
+			return 0, err
+		}
+	}
+	return counter, nil
+}
+
+// ensureExpiration gives a window to a key left without one, e.g. when the
+// expiration that followed its first increment failed. Without it the key
+// would keep counting forever and end up blocking the client for good. The
+// counter is kept when the expiration cannot be set either: deleting it would
+// let the client through, the next attempt tries again.
+func ensureExpiration(ctx context.Context, storage strategy.StorageStrategy, key string, ttlSeconds int) error {
+	reader, ok := storage.(strategy.TTLReader)
+	if !ok {
+		return nil
+	}
+	ttl, err := reader.TTL(ctx, key)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil || ttl > 0 {
+		return err
+	}
+	return storage.SetExpiration(ctx, key, ttlSeconds)
+}
+
+func remaining(limit int, counter int) int {
+	if counter >= limit {
+		return 0
+	}
+	return limit - counter
 }
 
 // Reset resets the counter for a specific key (useful for testing)
 func (rl *RateLimiter) Reset(ctx context.Context, ip string, token string) error {
 	if token != "" {
-		key := fmt.Sprintf("limiter:token:%s", token)
-		return rl.storage.Delete(ctx, key)
+		now := rl.clock.Now()
+		for _, window := range rl.quotas[token] {
+			start, _ := windowBounds(window.Period, now, rl.quotaLocation)
+			if err := rl.storage.Delete(ctx, quotaKey(token, window.Period, start)); err != nil {
+				return err
+			}
+		}
+		return rl.storage.Delete(ctx, clientKey("token", token))
 	}
 
 	if ip != "" {
-		key := fmt.Sprintf("limiter:ip:%s", ip)
-		return rl.storage.Delete(ctx, key)
+		return rl.storage.Delete(ctx, clientKey("ip", ip))
 	}
 
 	return nil
 }
 
-// Close closes the underlying storage connection
+// Close closes the underlying storage connection and the fallback storage
 func (rl *RateLimiter) Close() error {
-	return rl.storage.Close()
+	err := rl.storage.Close()
+	if rl.fallback != nil && rl.fallback != rl.storage {
+		err = errors.Join(err, rl.fallback.Close())
+	}
+	return err
 }
@@ -2,40 +2,78 @@ package limiter
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
 )
 
-// MockStorage is a mock implementation of StorageStrategy for testing
+// MockStorage is a mock implementation of StorageStrategy for testing. Keys
+// expire according to its clock, so tests can move time with a fake clock.
 type MockStorage struct {
-	counters map[string]int
+	counters  map[string]int
+	expiresAt map[string]time.Time
+	clock     clock.Clock
 }
 
 func NewMockStorage() *MockStorage {
+	return NewMockStorageWithClock(clock.Real)
+}
+
+func NewMockStorageWithClock(clock clock.Clock) *MockStorage {
 	return &MockStorage{
-		counters: make(map[string]int),
+		counters:  make(map[string]int),
+		expiresAt: make(map[string]time.Time),
+		clock:     clock,
+	}
+}
+
+// expire drops the key once its expiration has passed
+func (m *MockStorage) expire(key string) {
+	if expiresAt, exists := m.expiresAt[key]; exists && !m.clock.Now().Before(expiresAt) {
+		delete(m.counters, key)
+		delete(m.expiresAt, key)
 	}
 }
 
 func (m *MockStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	m.expire(key)
 	m.counters[key]++
 	return m.counters[key], nil
 }
 
 func (m *MockStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	m.expire(key)
+	if _, exists := m.counters[key]; exists {
+		m.expiresAt[key] = m.clock.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	}
 	return nil
 }
 
+func (m *MockStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.expire(key)
+	if expiresAt, exists := m.expiresAt[key]; exists {
+		return expiresAt.Sub(m.clock.Now()), nil
+	}
+	return 0, nil
+}
+
 func (m *MockStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	m.expire(key)
 	return m.counters[key], nil
 }
 
 func (m *MockStorage) Exists(ctx context.Context, key string) (bool, error) {
+	m.expire(key)
 	_, exists := m.counters[key]
 	return exists, nil
 }
 
 func (m *MockStorage) Delete(ctx context.Context, key string) error {
 	delete(m.counters, key)
+	delete(m.expiresAt, key)
 	return nil
 }
 
@@ -43,6 +81,35 @@ func (m *MockStorage) Close() error {
 	return nil
 }
 
+// FailingStorage is a StorageStrategy that always fails, simulating a storage outage
+type FailingStorage struct{}
+
+var errStorageDown = errors.New("storage down")
+
+func (f *FailingStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	return 0, errStorageDown
+}
+
+func (f *FailingStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	return errStorageDown
+}
+
+func (f *FailingStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	return 0, errStorageDown
+}
+
+func (f *FailingStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return false, errStorageDown
+}
+
+func (f *FailingStorage) Delete(ctx context.Context, key string) error {
+	return errStorageDown
+}
+
+func (f *FailingStorage) Close() error {
+	return nil
+}
+
 // TestIPRateLimit tests rate limiting by IP address
 func TestIPRateLimit(t *testing.T) {
 	storage := NewMockStorage()
@@ -159,6 +226,241 @@ func TestReset(t *testing.T) {
 	}
 }
 
+// TestKeysUseHashTags tests that client identifiers are wrapped in Redis Cluster hash tags
+func TestKeysUseHashTags(t *testing.T) {
+	storage := NewMockStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	limiter.Allow(ctx, "192.168.1.1", "")
+	limiter.Allow(ctx, "192.168.1.1", "token123")
+
+	for _, key := range []string{"limiter:ip:{192.168.1.1}", "limiter:token:{token123}"} {
+		if storage.counters[key] != 1 {
+			t.Fatalf("Expected counter for key %s, got %v", key, storage.counters)
+		}
+	}
+}
+
+// TestBlockedClientsAreCachedLocally tests that the limiter reports blocks to a caching storage
+func TestBlockedClientsAreCachedLocally(t *testing.T) {
+	inner := strategy.NewMemoryStorage()
+	limiter := NewRateLimiter(strategy.NewCachedStorage(inner, 0, 100, 1000), 1, 300)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	limiter.Allow(ctx, "192.168.1.1", "")
+	limiter.Allow(ctx, "192.168.1.1", "")
+
+	// Resetting only the wrapped storage leaves the local block in place
+	inner.Delete(ctx, "limiter:ip:{192.168.1.1}")
+
+	allowed, err := limiter.Allow(ctx, "192.168.1.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatal("Blocked client should be denied from the local cache")
+	}
+}
+
+// TestFailClosed tests that requests are denied with ErrStorageUnavailable when the storage fails
+func TestFailClosed(t *testing.T) {
+	limiter := NewRateLimiter(&FailingStorage{}, 5, 300)
+	defer limiter.Close()
+
+	allowed, err := limiter.Allow(context.Background(), "192.168.1.1", "")
+	if !errors.Is(err, ErrStorageUnavailable) {
+		t.Fatalf("Expected ErrStorageUnavailable, got %v", err)
+	}
+	if allowed {
+		t.Fatal("Request should be denied when failing closed")
+	}
+}
+
+// TestFailOpen tests that requests are allowed when the storage fails
+func TestFailOpen(t *testing.T) {
+	limiter := NewRateLimiter(&FailingStorage{}, 1, 300)
+	limiter.SetFailureMode(FailOpen, nil)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx, "192.168.1.1", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("Request %d should be allowed when failing open", i+1)
+		}
+	}
+}
+
+// TestFailLocal tests that the local fallback storage enforces limits when the storage fails
+func TestFailLocal(t *testing.T) {
+	limiter := NewRateLimiter(&FailingStorage{}, 2, 300)
+	limiter.SetFailureMode(FailLocal, strategy.NewMemoryStorage())
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		allowed, err := limiter.Allow(ctx, "192.168.1.1", "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !allowed {
+			t.Fatalf("Request %d should be allowed by the local fallback", i+1)
+		}
+	}
+
+	allowed, err := limiter.Allow(ctx, "192.168.1.1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatal("3rd request should be denied by the local fallback")
+	}
+}
+
+// TestParseFailureMode tests parsing of failure mode configuration values
+func TestParseFailureMode(t *testing.T) {
+	for _, value := range []string{"closed", "open", "local"} {
+		if _, err := ParseFailureMode(value); err != nil {
+			t.Fatalf("Unexpected error for %q: %v", value, err)
+		}
+	}
+
+	if _, err := ParseFailureMode("sometimes"); err == nil {
+		t.Fatal("Expected error for invalid failure mode")
+	}
+}
+
+// TestAllowNIsAllOrNothing tests that a cost is consumed only when it fits entirely
+func TestAllowNIsAllOrNothing(t *testing.T) {
+	for name, storage := range map[string]strategy.StorageStrategy{
+		"non-atomic": NewMockStorage(),
+		"atomic":     strategy.NewMemoryStorage(),
+	} {
+		limiter := NewRateLimiter(storage, 10, 300)
+		ctx := context.Background()
+
+		if allowed, _ := limiter.AllowN(ctx, "192.168.1.1", "", 8); !allowed {
+			t.Fatalf("%s: cost within the limit should be allowed", name)
+		}
+		if allowed, _ := limiter.AllowN(ctx, "192.168.1.1", "", 3); allowed {
+			t.Fatalf("%s: cost above what is left should be denied", name)
+		}
+		if counter, _ := storage.GetCounter(ctx, "limiter:ip:{192.168.1.1}"); counter != 8 {
+			t.Fatalf("%s: denied cost should not be consumed, counter is %d", name, counter)
+		}
+		if allowed, _ := limiter.AllowN(ctx, "192.168.1.1", "", 0); !allowed {
+			t.Fatalf("%s: zero cost should be allowed while not blocked", name)
+		}
+		if allowed, _ := limiter.AllowN(ctx, "192.168.1.1", "", 2); !allowed {
+			t.Fatalf("%s: remaining units should be allowed", name)
+		}
+		if allowed, _ := limiter.AllowN(ctx, "192.168.1.1", "", 0); allowed {
+			t.Fatalf("%s: zero cost should be denied once blocked", name)
+		}
+		if _, err := limiter.AllowN(ctx, "192.168.1.1", "", -1); err == nil {
+			t.Fatalf("%s: negative cost should be rejected", name)
+		}
+	}
+}
+
+// plainStorage hides the optional interfaces of the storage it wraps, like a
+// custom storage that only implements StorageStrategy
+type plainStorage struct {
+	strategy.StorageStrategy
+}
+
+// TestCircuitBreakerAroundPlainStorage tests that the capabilities the breaker
+// cannot forward fall back to the basic calls instead of failing decisions
+func TestCircuitBreakerAroundPlainStorage(t *testing.T) {
+	storage := strategy.NewCircuitBreakerStorage(plainStorage{NewMockStorage()}, 5, 30)
+	limiter := NewRateLimiter(storage, 5, 300)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	if allowed, err := limiter.AllowN(ctx, "192.168.1.1", "", 3); !allowed || err != nil {
+		t.Fatalf("Cost above 1 should be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, err := limiter.AllowN(ctx, "192.168.1.1", "", 2); !allowed || err != nil {
+		t.Fatalf("Remaining units should be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, err := limiter.Allow(ctx, "192.168.1.1", ""); allowed || err != nil {
+		t.Fatalf("Client at the limit should be denied without an error, got %v, %v", allowed, err)
+	}
+	if state := storage.State(); state != strategy.CircuitClosed {
+		t.Fatalf("Circuit should stay closed, got %v", state)
+	}
+}
+
+// TestParsePolicies tests parsing of policy configuration values
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies("search=100/60, export=5/3600, strict=50/60/shadow")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policies) != 3 || policies[1] != (Policy{Name: "export", Limit: 5, BlockDuration: 3600}) || policies[2] != (Policy{Name: "strict", Limit: 50, BlockDuration: 60, Shadow: true}) {
+		t.Fatalf("Unexpected policies %+v", policies)
+	}
+
+	for _, invalid := range []string{"search", "search=100", "=1/1", "search=1/0", "search=1/60/dry"} {
+		if _, err := ParsePolicies(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestCheckPolicyKeysAreIsolated tests that policies and key parts get separate counters
+func TestCheckPolicyKeysAreIsolated(t *testing.T) {
+	storage := NewMockStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigurePolicy("search", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	if decision, _ := limiter.Check(ctx, "search", []string{"tenant", "a"}, 1); !decision.Allowed {
+		t.Fatal("First check should be allowed")
+	}
+	if decision, _ := limiter.Check(ctx, "search", []string{"tenant", "a"}, 1); decision.Allowed {
+		t.Fatal("Second check should be denied")
+	}
+	if decision, _ := limiter.Check(ctx, "search", []string{"tenant", "b"}, 1); !decision.Allowed {
+		t.Fatal("Other key should have its own counter")
+	}
+	if storage.counters["limiter:policy:search:{tenant:a}"] != 1 {
+		t.Fatalf("Unexpected counters %v", storage.counters)
+	}
+}
+
+// TestCheckKeyPartsDoNotCollide tests that parts containing the separator get their own counter
+func TestCheckKeyPartsDoNotCollide(t *testing.T) {
+	storage := NewMockStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigurePolicy("search", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	if decision, _ := limiter.Check(ctx, "search", []string{"tenant", "a"}, 1); !decision.Allowed {
+		t.Fatal("First check should be allowed")
+	}
+	for _, parts := range [][]string{{"tenant:a"}, {"tenant\\", "a"}, {"tenant\\:a"}} {
+		if decision, _ := limiter.Check(ctx, "search", parts, 1); !decision.Allowed {
+			t.Fatalf("Parts %q should not share the counter of [tenant a]", parts)
+		}
+	}
+	if storage.counters[`limiter:policy:search:{tenant\:a}`] != 1 {
+		t.Fatalf("Unexpected counters %v", storage.counters)
+	}
+}
+
 // BenchmarkAllow benchmarks the Allow function
 func BenchmarkAllow(b *testing.B) {
 	storage := NewMockStorage()
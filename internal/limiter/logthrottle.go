@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// logInterval is the minimum time between two messages of a throttled log
+const logInterval = 10 * time.Second
+
+// throttledLog keeps messages logged on the request path, e.g. during a
+// storage outage, from flooding the log. The first message is logged at
+// once, later ones at most once per interval with the number suppressed in
+// between, and the end of the outage once.
+type throttledLog struct {
+	interval   time.Duration
+	active     atomic.Bool
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// Printf logs the message unless another one was logged within the interval
+func (t *throttledLog) Printf(format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interval := t.interval
+	if interval == 0 {
+		interval = logInterval
+	}
+	now := time.Now()
+	if t.active.Load() && now.Sub(t.last) < interval {
+		t.suppressed++
+		return
+	}
+
+	if t.suppressed > 0 {
+		format += " (%d similar messages suppressed)"
+		args = append(args, t.suppressed)
+	}
+	log.Printf(format, args...)
+	t.active.Store(true)
+	t.last = now
+	t.suppressed = 0
+}
+
+// Recovered logs message once after messages were logged, and lets the next
+// one through at once. It is cheap enough to call on every success.
+func (t *throttledLog) Recovered(message string) {
+	if !t.active.Load() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.active.Load() {
+		return
+	}
+	if t.suppressed > 0 {
+		log.Printf("%s (%d similar messages suppressed)", message, t.suppressed)
+	} else {
+		log.Print(message)
+	}
+	t.active.Store(false)
+	t.suppressed = 0
+}
@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// TestFailOpenLogsOncePerOutage tests that an outage is logged when it starts
+// and ends, not on every request
+func TestFailOpenLogsOncePerOutage(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{ErrorRate: 1})
+	limiter := NewRateLimiter(storage, 1000, 60)
+	limiter.SetFailureMode(FailOpen, nil)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if allowed, err := limiter.Allow(ctx, "192.168.1.1", ""); !allowed || err != nil {
+			t.Fatalf("Expected the request to be allowed, got %v, %v", allowed, err)
+		}
+	}
+	storage.SetConfig(strategy.FaultConfig{})
+	limiter.Allow(ctx, "192.168.1.1", "")
+	limiter.Allow(ctx, "192.168.1.1", "")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "allowing") || !strings.Contains(lines[1], "recovered (99 similar messages suppressed)") {
+		t.Fatalf("Expected one failure and one recovery line, got:\n%s", output.String())
+	}
+}
+
+type closeRecorder struct {
+	*strategy.MemoryStorage
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return errors.New("close failed")
+}
+
+// TestCloseClosesFallback tests that the fallback storage is closed with the limiter
+func TestCloseClosesFallback(t *testing.T) {
+	fallback := &closeRecorder{MemoryStorage: strategy.NewMemoryStorage()}
+	limiter := NewRateLimiter(strategy.NewMemoryStorage(), 5, 60)
+	limiter.SetFailureMode(FailLocal, fallback)
+
+	if err := limiter.Close(); err == nil || !fallback.closed {
+		t.Fatalf("Expected the fallback to be closed and its error returned, got %v", err)
+	}
+}
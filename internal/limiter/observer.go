@@ -0,0 +1,36 @@
+package limiter
+
+import "time"
+
+// Event is a decision made for a client request by Allow, AllowN or Decide
+type Event struct {
+	IP    string
+	Token string
+	Cost  int
+	// Decision holds the outcome, Reset is only set for Decide and quotas
+	Decision Decision
+	Time     time.Time
+	// Key is the counter key of clients without quotas
+	Key string
+	// Quotas is the usage of the quota windows evaluated for the token,
+	// the last one is the denying window when the request was denied
+	Quotas []QuotaUsage
+}
+
+// Observer is notified of every client decision. Observers run on the request
+// path, so they must return quickly and do any I/O asynchronously.
+type Observer interface {
+	Observe(event Event)
+}
+
+// AddObserver registers an observer of client decisions. Observers must be
+// added before the limiter starts serving requests.
+func (rl *RateLimiter) AddObserver(observer Observer) {
+	rl.observers = append(rl.observers, observer)
+}
+
+func (rl *RateLimiter) notify(event Event) {
+	for _, observer := range rl.observers {
+		observer.Observe(event)
+	}
+}
@@ -0,0 +1,177 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// ErrUnknownPolicy is returned when a check references a policy that is not configured
+var ErrUnknownPolicy = errors.New("unknown rate limit policy")
+
+// DefaultPolicy is the policy name that always exists and uses the default limit
+const DefaultPolicy = "default"
+
+// Policy is a named limit that can be applied to arbitrary keys
+type Policy struct {
+	Name          string
+	Limit         int
+	BlockDuration int
+	// Shadow policies are evaluated and counted but never deny
+	Shadow bool
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time left until the window resets
+	Reset time.Duration
+	// ShadowDenied is set when a shadow policy would have denied the request
+	ShadowDenied bool
+}
+
+// ConfigurePolicy sets a named policy used by Check
+func (rl *RateLimiter) ConfigurePolicy(name string, limit int, blockDuration int) {
+	rl.policies[name] = Policy{Name: name, Limit: limit, BlockDuration: blockDuration}
+}
+
+// ConfigureShadowPolicy sets a named policy that Check evaluates and counts
+// but never enforces, see ShadowStats
+func (rl *RateLimiter) ConfigureShadowPolicy(name string, limit int, blockDuration int) {
+	rl.policies[name] = Policy{Name: name, Limit: limit, BlockDuration: blockDuration, Shadow: true}
+}
+
+// Policy returns the named policy, falling back to the default limit for DefaultPolicy
+func (rl *RateLimiter) Policy(name string) (Policy, bool) {
+	if policy, exists := rl.policies[name]; exists {
+		return policy, true
+	}
+	if name == DefaultPolicy {
+		return Policy{Name: DefaultPolicy, Limit: rl.defaultLimit, BlockDuration: rl.defaultBlockDuration}, true
+	}
+	return Policy{}, false
+}
+
+// keyPartEscaper escapes the separator in key parts
+var keyPartEscaper = strings.NewReplacer(`\`, `\\`, ":", `\:`)
+
+// policyKey builds the storage key of a policy from the key parts. Parts are
+// escaped, so ["a:b"] and ["a", "b"] never share a counter.
+func policyKey(policyName string, keyParts []string) string {
+	escaped := make([]string, len(keyParts))
+	for i, part := range keyParts {
+		escaped[i] = keyPartEscaper.Replace(part)
+	}
+	return clientKey("policy:"+policyName, strings.Join(escaped, ":"))
+}
+
+// Check consumes cost units of the named policy for the key built from keyParts
+// and reports the full decision, including the time until the window resets
+func (rl *RateLimiter) Check(ctx context.Context, policyName string, keyParts []string, cost int) (Decision, error) {
+	policy, exists := rl.Policy(policyName)
+	if !exists {
+		return Decision{}, fmt.Errorf("%w: %s", ErrUnknownPolicy, policyName)
+	}
+	if cost < 0 {
+		return Decision{}, fmt.Errorf("invalid cost %d", cost)
+	}
+
+	ctx, cancel := rl.withTimeout(ctx)
+	defer cancel()
+
+	key := policyKey(policy.Name, keyParts)
+	decision, err := rl.decide(ctx, key, policy.Limit, policy.BlockDuration, cost)
+	if err != nil {
+		return decision, err
+	}
+
+	decision.Reset = rl.resetAfter(ctx, key, policy.BlockDuration)
+	if policy.Shadow {
+		rl.recordShadow(policy.Name, key, &decision)
+	}
+	return decision, nil
+}
+
+// Block marks the key of the named policy as over its limit for at least d,
+// e.g. when an upstream asks us to back off. The block lives in the storage,
+// so every instance sharing it honors it.
+func (rl *RateLimiter) Block(ctx context.Context, policyName string, keyParts []string, d time.Duration) error {
+	policy, exists := rl.Policy(policyName)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownPolicy, policyName)
+	}
+
+	key := policyKey(policy.Name, keyParts)
+	counter, err := rl.storage.GetCounter(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	if counter < policy.Limit {
+		if _, err := incrementBy(ctx, rl.storage, key, policy.Limit-counter); err != nil {
+			return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+		}
+	}
+
+	// Never shorten a longer window or block that is already in place
+	if counter > 0 && rl.resetAfter(ctx, key, 0) >= d {
+		return nil
+	}
+
+	seconds := int(math.Ceil(d.Seconds()))
+	// If this fails the counter is kept, the next check gives it an expiration
+	if err := rl.storage.SetExpiration(ctx, key, seconds); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return nil
+}
+
+// resetAfter reports the time left on the key, falling back to the full
+// window when the storage cannot tell
+func (rl *RateLimiter) resetAfter(ctx context.Context, key string, blockDuration int) time.Duration {
+	if reader, ok := rl.storage.(strategy.TTLReader); ok {
+		if ttl, err := reader.TTL(ctx, key); err == nil && ttl > 0 {
+			return ttl
+		}
+	}
+	return time.Duration(blockDuration) * time.Second
+}
+
+// ParsePolicies parses a comma separated list of name=limit/seconds entries,
+// e.g. "search=100/60,export=5/3600". A "/shadow" suffix makes a shadow
+// policy, e.g. "strict-search=50/60/shadow".
+func ParsePolicies(value string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var policy Policy
+		name, spec, found := strings.Cut(entry, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid policy %q (expected name=limit/seconds)", entry)
+		}
+		spec, policy.Shadow = strings.CutSuffix(spec, "/shadow")
+		// Parse strictly so a misspelled suffix is not silently enforced
+		limit, seconds, _ := strings.Cut(spec, "/")
+		parsedLimit, limitErr := strconv.Atoi(limit)
+		parsedSeconds, secondsErr := strconv.Atoi(seconds)
+		if limitErr != nil || secondsErr != nil || parsedLimit < 0 || parsedSeconds <= 0 {
+			return nil, fmt.Errorf("invalid policy %q (expected name=limit/seconds)", entry)
+		}
+		policy.Name = name
+		policy.Limit = parsedLimit
+		policy.BlockDuration = parsedSeconds
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
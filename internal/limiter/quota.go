@@ -0,0 +1,267 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// Period is the length of a quota window. Windows are aligned to calendar
+// boundaries in the quota location, not to the first request.
+type Period string
+
+const (
+	PeriodSecond Period = "second"
+	PeriodMinute Period = "minute"
+	PeriodHour   Period = "hour"
+	PeriodDay    Period = "day"
+	PeriodMonth  Period = "month"
+)
+
+// periodOrder sorts windows from the shortest to the longest
+var periodOrder = map[Period]int{
+	PeriodSecond: 0,
+	PeriodMinute: 1,
+	PeriodHour:   2,
+	PeriodDay:    3,
+	PeriodMonth:  4,
+}
+
+// QuotaWindow allows Limit units per Period
+type QuotaWindow struct {
+	Period Period
+	Limit  int
+}
+
+// QuotaUsage is the state of a token quota window
+type QuotaUsage struct {
+	Period    Period
+	Limit     int
+	Used      int
+	Remaining int
+	// ResetsAt is the end of the current window
+	ResetsAt time.Time
+}
+
+// ConfigureQuotas sets stacked quota windows for a token, e.g. 10 per second
+// and 1,000,000 per month. Every window must have room for a request to be
+// allowed. Tokens with quotas use them instead of their single window.
+// Unknown periods and negative limits are rejected.
+func (rl *RateLimiter) ConfigureQuotas(token string, windows ...QuotaWindow) error {
+	for _, window := range windows {
+		if _, known := periodOrder[window.Period]; !known {
+			return fmt.Errorf("invalid quota for token %q: unknown period %q (expected second, minute, hour, day or month)", token, window.Period)
+		}
+		if window.Limit < 0 {
+			return fmt.Errorf("invalid quota for token %q: negative limit %d", token, window.Limit)
+		}
+	}
+
+	sorted := make([]QuotaWindow, len(windows))
+	copy(sorted, windows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return periodOrder[sorted[i].Period] < periodOrder[sorted[j].Period]
+	})
+	rl.quotas[token] = sorted
+	return nil
+}
+
+// SetQuotaLocation sets the time zone quota windows are aligned to, e.g. the
+// billing time zone so monthly quotas reset with the invoice. Defaults to UTC.
+func (rl *RateLimiter) SetQuotaLocation(loc *time.Location) {
+	rl.quotaLocation = loc
+}
+
+// QuotaUsage reports the usage of every quota window of a token. It returns
+// nil when the token has no quotas.
+func (rl *RateLimiter) QuotaUsage(ctx context.Context, token string) ([]QuotaUsage, error) {
+	return rl.quotaUsage(ctx, token, rl.clock.Now())
+}
+
+func (rl *RateLimiter) quotaUsage(ctx context.Context, token string, now time.Time) ([]QuotaUsage, error) {
+	windows := rl.quotas[token]
+	usage := make([]QuotaUsage, 0, len(windows))
+	for _, window := range windows {
+		start, end := windowBounds(window.Period, now, rl.quotaLocation)
+		used, err := rl.storage.GetCounter(ctx, quotaKey(token, window.Period, start))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+		}
+		usage = append(usage, QuotaUsage{
+			Period:    window.Period,
+			Limit:     window.Limit,
+			Used:      used,
+			Remaining: remaining(window.Limit, used),
+			ResetsAt:  end,
+		})
+	}
+	if len(usage) == 0 {
+		return nil, nil
+	}
+	return usage, nil
+}
+
+// decideQuotas consumes cost units from every quota window of the token,
+// applying the failure mode on storage errors. It also reports the usage of
+// the windows that were evaluated.
+func (rl *RateLimiter) decideQuotas(ctx context.Context, token string, windows []QuotaWindow, cost int, now time.Time) (Decision, []QuotaUsage, error) {
+	key := clientKey("quota", "token:"+token)
+	var usage []QuotaUsage
+	decision, err := rl.withFailureMode(ctx, key, windows[0].Limit, func(ctx context.Context, storage strategy.StorageStrategy) (Decision, error) {
+		var decision Decision
+		var err error
+		decision, usage, err = rl.consumeQuotas(ctx, storage, token, windows, cost, now)
+		return decision, err
+	})
+	if err != nil {
+		return decision, nil, err
+	}
+	return decision, usage, nil
+}
+
+// consumeQuotas consumes cost units from each window, shortest first. When a
+// window has no room, the units already taken from the shorter ones are
+// returned and the remaining windows are not evaluated. The decision reports
+// the denying window, or the window with the fewest units left when allowed.
+func (rl *RateLimiter) consumeQuotas(ctx context.Context, storage strategy.StorageStrategy, token string, windows []QuotaWindow, cost int, now time.Time) (Decision, []QuotaUsage, error) {
+	var result Decision
+	var consumed []string
+	usage := make([]QuotaUsage, 0, len(windows))
+	for i, window := range windows {
+		start, end := windowBounds(window.Period, now, rl.quotaLocation)
+		key := quotaKey(token, window.Period, start)
+
+		// The counter expires with its window
+		ttl := int(math.Ceil(end.Sub(now).Seconds()))
+		decision, err := rl.checkStorage(ctx, storage, key, window.Limit, max(ttl, 1), cost)
+		if err != nil {
+			refund(ctx, storage, consumed, cost)
+			return Decision{Limit: window.Limit}, nil, err
+		}
+		decision.Reset = end.Sub(now)
+		usage = append(usage, QuotaUsage{
+			Period:    window.Period,
+			Limit:     window.Limit,
+			Used:      window.Limit - decision.Remaining,
+			Remaining: decision.Remaining,
+			ResetsAt:  end,
+		})
+
+		if !decision.Allowed {
+			refund(ctx, storage, consumed, cost)
+			return decision, usage, nil
+		}
+		if cost > 0 {
+			consumed = append(consumed, key)
+		}
+		if i == 0 || decision.Remaining < result.Remaining {
+			result = decision
+		}
+	}
+	return result, usage, nil
+}
+
+// refundTimeout bounds a refund, which runs even when the decision ran out of time
+const refundTimeout = time.Second
+
+// refund returns cost units to the keys. Storages that cannot decrement keep
+// the units, which only errs on the side of denying. Like reservations, a
+// window that expired in the meantime gets nothing back: decrementing it would
+// recreate the key as a negative counter without expiration.
+func refund(ctx context.Context, storage strategy.StorageStrategy, keys []string, cost int) {
+	incrementer, ok := storage.(strategy.BatchIncrementer)
+	if !ok || len(keys) == 0 {
+		return
+	}
+
+	// The decision context may be the one that just timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refundTimeout)
+	defer cancel()
+	for _, key := range keys {
+		if refundable(ctx, storage, key, cost) {
+			incrementer.IncrementCounterBy(ctx, key, -cost)
+		}
+	}
+}
+
+// refundable reports whether the key still holds cost units of a live window
+func refundable(ctx context.Context, storage strategy.StorageStrategy, key string, cost int) bool {
+	if reader, ok := storage.(strategy.TTLReader); ok {
+		ttl, err := reader.TTL(ctx, key)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return false
+		}
+		if err == nil && ttl <= 0 {
+			return false
+		}
+	}
+	counter, err := storage.GetCounter(ctx, key)
+	return err == nil && counter >= cost
+}
+
+// quotaKey builds the counter key of a token quota window, the window start
+// makes each window use a fresh counter
+func quotaKey(token string, period Period, start time.Time) string {
+	return clientKey(fmt.Sprintf("quota:%s:%d", period, start.Unix()), "token:"+token)
+}
+
+// windowBounds returns the calendar window of the period that contains t
+func windowBounds(period Period, t time.Time, loc *time.Location) (time.Time, time.Time) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	var start time.Time
+	switch period {
+	case PeriodSecond:
+		start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		return start, start.Add(time.Second)
+	case PeriodMinute:
+		start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+		return start, start.Add(time.Minute)
+	case PeriodHour:
+		start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		return start, start.Add(time.Hour)
+	case PeriodDay:
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1)
+	default:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// ParseQuotas parses a comma separated list of token=limit/period entries,
+// with windows of the same token separated by ";", e.g.
+// "token123=10/second;1000000/month,premium-token=100/second"
+func ParseQuotas(value string) (map[string][]QuotaWindow, error) {
+	quotas := make(map[string][]QuotaWindow)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		token, spec, found := strings.Cut(entry, "=")
+		if !found || token == "" || spec == "" {
+			return nil, fmt.Errorf("invalid quota %q (expected token=limit/period;...)", entry)
+		}
+		for _, part := range strings.Split(spec, ";") {
+			limit, period, found := strings.Cut(strings.TrimSpace(part), "/")
+			parsed, err := strconv.Atoi(limit)
+			if _, known := periodOrder[Period(period)]; !found || !known || err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid quota %q (expected token=limit/period with period second, minute, hour, day or month)", entry)
+			}
+			quotas[token] = append(quotas[token], QuotaWindow{Period: Period(period), Limit: parsed})
+		}
+	}
+	return quotas, nil
+}
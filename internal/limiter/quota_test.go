@@ -0,0 +1,185 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// TestWindowBounds tests that windows are aligned to calendar boundaries in the location
+func TestWindowBounds(t *testing.T) {
+	saoPaulo := time.FixedZone("BRT", -3*3600)
+	now := time.Date(2026, 1, 31, 23, 30, 15, 500, time.UTC)
+
+	cases := []struct {
+		period Period
+		loc    *time.Location
+		start  time.Time
+		end    time.Time
+	}{
+		{PeriodSecond, time.UTC, time.Date(2026, 1, 31, 23, 30, 15, 0, time.UTC), time.Date(2026, 1, 31, 23, 30, 16, 0, time.UTC)},
+		{PeriodMinute, time.UTC, time.Date(2026, 1, 31, 23, 30, 0, 0, time.UTC), time.Date(2026, 1, 31, 23, 31, 0, 0, time.UTC)},
+		{PeriodDay, time.UTC, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{PeriodMonth, time.UTC, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// 20:30 in São Paulo, still January 31st there
+		{PeriodDay, saoPaulo, time.Date(2026, 1, 31, 0, 0, 0, 0, saoPaulo), time.Date(2026, 2, 1, 0, 0, 0, 0, saoPaulo)},
+		{PeriodMonth, saoPaulo, time.Date(2026, 1, 1, 0, 0, 0, 0, saoPaulo), time.Date(2026, 2, 1, 0, 0, 0, 0, saoPaulo)},
+	}
+
+	for _, c := range cases {
+		start, end := windowBounds(c.period, now, c.loc)
+		if !start.Equal(c.start) || !end.Equal(c.end) {
+			t.Fatalf("%s in %s: expected [%v, %v), got [%v, %v)", c.period, c.loc, c.start, c.end, start, end)
+		}
+	}
+}
+
+// TestStackedQuotas tests that every window is enforced and a denied request
+// does not consume the shorter windows
+func TestStackedQuotas(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigureQuotas("plan-token", QuotaWindow{Period: PeriodMonth, Limit: 4}, QuotaWindow{Period: PeriodSecond, Limit: 3})
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	// The per-second window is the first to run out
+	for i := 0; i < 3; i++ {
+		if decision, _, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, now); !decision.Allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+	decision, _, err := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, now)
+	if err != nil || decision.Allowed || decision.Limit != 3 || decision.Reset != time.Second {
+		t.Fatalf("Per-second window should deny, got %+v, %v", decision, err)
+	}
+
+	// Next second: the monthly window has one unit left
+	now = now.Add(time.Second)
+	if decision, _, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 2, now); decision.Allowed {
+		t.Fatal("Monthly window should deny a cost above what is left")
+	}
+	if counter, _ := storage.GetCounter(ctx, quotaKey("plan-token", PeriodSecond, now)); counter != 0 {
+		t.Fatalf("Denied request should be refunded from the per-second window, counter is %d", counter)
+	}
+
+	decision, usage, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, now)
+	if !decision.Allowed || decision.Limit != 4 || decision.Remaining != 0 {
+		t.Fatalf("Last monthly unit should be allowed and reported, got %+v", decision)
+	}
+	if len(usage) != 2 || usage[0].Used != 1 || usage[1].Used != 4 {
+		t.Fatalf("Decision should report the usage of every window, got %+v", usage)
+	}
+
+	usage, err = limiter.quotaUsage(ctx, "plan-token", now)
+	if err != nil || len(usage) != 2 {
+		t.Fatalf("Unexpected usage %+v, %v", usage, err)
+	}
+	if usage[1].Period != PeriodMonth || usage[1].Used != 4 || !usage[1].ResetsAt.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected monthly usage %+v", usage[1])
+	}
+
+	// The monthly counter does not carry over to the next billing period
+	nextMonth := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	if decision, _, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, nextMonth); !decision.Allowed {
+		t.Fatal("Quota should reset at the start of the month")
+	}
+}
+
+// slowWindowStorage moves the clock while the given key is consumed, like a
+// slow call during which a shorter window rolls over
+type slowWindowStorage struct {
+	*strategy.MemoryStorage
+	clock *clock.Fake
+	key   string
+	delay time.Duration
+}
+
+func (s *slowWindowStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	if key == s.key {
+		s.clock.Advance(s.delay)
+	}
+	return s.MemoryStorage.Consume(ctx, key, cost, limit, ttlSeconds)
+}
+
+// TestQuotaRefundSkipsExpiredWindow tests that units are not returned to a
+// window that expired between the consume and the refund
+func TestQuotaRefundSkipsExpiredWindow(t *testing.T) {
+	now := clock.NewFake(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	memory := strategy.NewMemoryStorageWithClock(now)
+	storage := &slowWindowStorage{MemoryStorage: memory, clock: now, key: quotaKey("plan-token", PeriodHour, now.Now()), delay: 2 * time.Second}
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigureQuotas("plan-token", QuotaWindow{Period: PeriodSecond, Limit: 3}, QuotaWindow{Period: PeriodHour, Limit: 1})
+	ctx := context.Background()
+
+	if decision, _, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, now.Now()); !decision.Allowed {
+		t.Fatal("First request should be allowed")
+	}
+
+	// The hourly window denies after the per-second window rolled over
+	start := now.Now()
+	if decision, _, _ := limiter.decideQuotas(ctx, "plan-token", limiter.quotas["plan-token"], 1, start); decision.Allowed {
+		t.Fatal("Hourly window should deny")
+	}
+	key := quotaKey("plan-token", PeriodSecond, start)
+	if exists, _ := memory.Exists(ctx, key); exists {
+		counter, _ := memory.GetCounter(ctx, key)
+		t.Fatalf("Expired window should not be recreated by the refund, counter is %d", counter)
+	}
+}
+
+// TestQuotasReplaceTokenWindow tests that Allow uses the quotas of a token
+func TestQuotasReplaceTokenWindow(t *testing.T) {
+	limiter := NewRateLimiter(strategy.NewMemoryStorage(), 1, 300)
+	limiter.ConfigureQuotas("plan-token", QuotaWindow{Period: PeriodDay, Limit: 3})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow(ctx, "192.168.1.1", "plan-token"); !allowed {
+			t.Fatalf("Request %d should be allowed by the daily quota", i+1)
+		}
+	}
+	if allowed, _ := limiter.Allow(ctx, "192.168.1.1", "plan-token"); allowed {
+		t.Fatal("Daily quota should be exhausted")
+	}
+
+	if usage, _ := limiter.QuotaUsage(ctx, "other-token"); usage != nil {
+		t.Fatalf("Tokens without quotas should report no usage, got %+v", usage)
+	}
+}
+
+// TestParseQuotas tests parsing of the quota configuration
+func TestParseQuotas(t *testing.T) {
+	quotas, err := ParseQuotas("token123=10/second;1000000/month, premium-token=100/second")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(quotas) != 2 || len(quotas["token123"]) != 2 || quotas["token123"][1] != (QuotaWindow{Period: PeriodMonth, Limit: 1000000}) {
+		t.Fatalf("Unexpected quotas %+v", quotas)
+	}
+
+	for _, invalid := range []string{"token123", "token123=10", "token123=10/week", "=10/second", "token123=x/day"} {
+		if _, err := ParseQuotas(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestConfigureQuotasRejectsInvalidWindows tests that unknown periods are not silently treated as monthly
+func TestConfigureQuotasRejectsInvalidWindows(t *testing.T) {
+	limiter := NewRateLimiter(NewMockStorage(), 5, 300)
+	defer limiter.Close()
+
+	if err := limiter.ConfigureQuotas("plan-token", QuotaWindow{Period: "week", Limit: 10}); err == nil {
+		t.Fatal("Expected error for an unknown period")
+	}
+	if err := limiter.ConfigureQuotas("plan-token", QuotaWindow{Period: PeriodDay, Limit: -1}); err == nil {
+		t.Fatal("Expected error for a negative limit")
+	}
+	if usage, _ := limiter.QuotaUsage(context.Background(), "plan-token"); usage != nil {
+		t.Fatalf("Rejected quotas must not be configured, got %+v", usage)
+	}
+}
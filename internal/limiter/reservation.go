@@ -0,0 +1,245 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// ErrExceedsLimit is returned when a reservation asks for more units than the policy allows per window
+var ErrExceedsLimit = errors.New("requested units exceed the policy limit")
+
+// ErrReservationCanceled is returned when waiting on a canceled reservation
+var ErrReservationCanceled = errors.New("reservation canceled")
+
+// Reservation holds units of a policy for a key. Units are consumed as soon as
+// they fit in the window; until then Delay reports how long to wait and Wait
+// blocks until they are consumed.
+type Reservation struct {
+	rl       *RateLimiter
+	policy   Policy
+	key      string
+	n        int
+	mu       sync.Mutex
+	consumed bool
+	canceled bool
+	readyAt  time.Time
+	// windowEnd is when the window the units were consumed in resets
+	windowEnd time.Time
+}
+
+// attempt is the outcome of trying to consume the reserved units
+type attempt struct {
+	consumed  bool
+	readyAt   time.Time
+	windowEnd time.Time
+}
+
+// Wait blocks until one unit of the named policy is available for the key and
+// consumes it, or returns the context error
+func (rl *RateLimiter) Wait(ctx context.Context, policyName string, keyParts []string) error {
+	reservation, err := rl.Reserve(ctx, policyName, keyParts, 1)
+	if err != nil {
+		return err
+	}
+	return reservation.Wait(ctx)
+}
+
+// Reserve reserves n units of the named policy for the key. The units are
+// consumed immediately when they fit in the current window, otherwise the
+// reservation reports the delay until the window resets.
+// Reservations only count against the policy: token quotas belong to the
+// per-request path (Allow and Decide) and are neither checked nor consumed.
+// Shadow policies never make a reservation wait, would-be delays are only
+// counted in ShadowStats.
+func (rl *RateLimiter) Reserve(ctx context.Context, policyName string, keyParts []string, n int) (*Reservation, error) {
+	policy, exists := rl.Policy(policyName)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPolicy, policyName)
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid cost %d", n)
+	}
+	if n > policy.Limit && !policy.Shadow {
+		return nil, fmt.Errorf("%w: %d > %d", ErrExceedsLimit, n, policy.Limit)
+	}
+
+	r := &Reservation{
+		rl:     rl,
+		policy: policy,
+		key:    policyKey(policy.Name, keyParts),
+		n:      n,
+	}
+	result, err := r.try(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.apply(result)
+	return r, nil
+}
+
+// try consumes the units if they fit, otherwise reports when to try again.
+// It only reads immutable fields, so it runs without holding the lock.
+func (r *Reservation) try(ctx context.Context) (attempt, error) {
+	ctx, cancel := r.rl.withTimeout(ctx)
+	defer cancel()
+
+	decision, err := r.rl.decide(ctx, r.key, r.policy.Limit, r.policy.BlockDuration, r.n)
+	if err != nil {
+		return attempt{}, err
+	}
+
+	if r.policy.Shadow {
+		r.rl.recordShadow(r.policy.Name, r.key, &decision)
+		if decision.ShadowDenied {
+			// Granted without taking units from the storage, so there is nothing to refund
+			return attempt{consumed: true}, nil
+		}
+	}
+
+	resetAt := r.rl.clock.Now().Add(r.rl.resetAfter(ctx, r.key, r.policy.BlockDuration))
+	if decision.Allowed {
+		return attempt{consumed: true, windowEnd: resetAt}, nil
+	}
+	return attempt{readyAt: resetAt}, nil
+}
+
+// apply records the outcome of try; the caller must hold the lock
+func (r *Reservation) apply(result attempt) {
+	r.consumed = result.consumed
+	r.readyAt = result.readyAt
+	r.windowEnd = result.windowEnd
+}
+
+// Delay reports how long to wait before the units can be consumed, zero once they are
+func (r *Reservation) Delay() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.consumed {
+		return 0
+	}
+	if delay := r.readyAt.Sub(r.rl.clock.Now()); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+// Wait blocks until the units are consumed or the context is done. Other
+// callers may take the new window first, in which case it keeps waiting.
+func (r *Reservation) Wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		if r.canceled {
+			r.mu.Unlock()
+			return ErrReservationCanceled
+		}
+		if r.consumed {
+			r.mu.Unlock()
+			return nil
+		}
+		delay := r.readyAt.Sub(r.rl.clock.Now())
+		r.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		result, err := r.try(ctx)
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		if !r.canceled {
+			r.apply(result)
+			r.mu.Unlock()
+			continue
+		}
+		r.mu.Unlock()
+
+		// Canceled while consuming, the units are not going to be used
+		if result.consumed {
+			if err := r.refund(ctx, result.windowEnd); err != nil {
+				return err
+			}
+		}
+		return ErrReservationCanceled
+	}
+}
+
+// Cancel gives up the reservation. Units already consumed are returned to the
+// window they were consumed in, when the storage supports it and that window
+// has not reset since. If returning them fails the reservation stays active,
+// so Cancel can be retried.
+func (r *Reservation) Cancel(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.canceled {
+		return nil
+	}
+	if r.consumed {
+		if err := r.refund(ctx, r.windowEnd); err != nil {
+			return err
+		}
+		r.consumed = false
+	}
+	r.canceled = true
+	return nil
+}
+
+// refund returns the units to the window that ends at windowEnd. Nothing is
+// returned once that window reset: the units would be extra capacity in the
+// next one.
+func (r *Reservation) refund(ctx context.Context, windowEnd time.Time) error {
+	if windowEnd.IsZero() {
+		return nil
+	}
+	incrementer, ok := r.rl.storage.(strategy.BatchIncrementer)
+	if !ok {
+		return errors.New("storage cannot return units")
+	}
+
+	reader, ok := r.rl.storage.(strategy.TTLReader)
+	if !ok {
+		return nil
+	}
+	ttl, err := reader.TTL(ctx, r.key)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	// A later window ends at least one window length after ours
+	tolerance := time.Duration(r.policy.BlockDuration) * time.Second / 2
+	if drift := r.rl.clock.Now().Add(ttl).Sub(windowEnd); drift > tolerance || drift < -tolerance {
+		return nil
+	}
+
+	counter, err := r.rl.storage.GetCounter(ctx, r.key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	if counter < r.n {
+		return nil
+	}
+	if _, err := incrementer.IncrementCounterBy(ctx, r.key, -r.n); errors.Is(err, errors.ErrUnsupported) {
+		return errors.New("storage cannot return units")
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return nil
+}
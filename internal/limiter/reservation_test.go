@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// TestWaitBlocksUntilWindowResets tests that Wait sleeps until a unit is available
+func TestWaitBlocksUntilWindowResets(t *testing.T) {
+	limiter := NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	limiter.ConfigurePolicy("jobs", 1, 1)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(ctx, "jobs", []string{"queue:emails"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("Second Wait should block until the window resets, took %v", elapsed)
+	}
+}
+
+// TestWaitRespectsContext tests that Wait returns when the context is done
+func TestWaitRespectsContext(t *testing.T) {
+	limiter := NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	limiter.ConfigurePolicy("jobs", 1, 60)
+
+	if err := limiter.Wait(context.Background(), "jobs", []string{"queue:emails"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "jobs", []string{"queue:emails"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+// TestReserve tests reservation delays and that canceling returns the units
+func TestReserve(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigurePolicy("export", 10, 60)
+	ctx := context.Background()
+
+	first, err := limiter.Reserve(ctx, "export", []string{"tenant", "acme"}, 8)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Delay() != 0 {
+		t.Fatalf("Units that fit should be consumed without delay, got %v", first.Delay())
+	}
+
+	second, err := limiter.Reserve(ctx, "export", []string{"tenant", "acme"}, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if delay := second.Delay(); delay <= 0 || delay > 60*time.Second {
+		t.Fatalf("Expected a delay until the window resets, got %v", delay)
+	}
+
+	// Returning the first reservation makes room for the second one
+	if err := first.Cancel(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if counter, _ := storage.GetCounter(ctx, "limiter:policy:export:{tenant:acme}"); counter != 0 {
+		t.Fatalf("Expected units to be returned, counter is %d", counter)
+	}
+
+	if err := second.Cancel(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := second.Wait(ctx); !errors.Is(err, ErrReservationCanceled) {
+		t.Fatalf("Expected canceled reservation, got %v", err)
+	}
+
+	if _, err := limiter.Reserve(ctx, "export", []string{"tenant", "acme"}, 11); !errors.Is(err, ErrExceedsLimit) {
+		t.Fatalf("Expected ErrExceedsLimit, got %v", err)
+	}
+}
+
+// TestReserveRejectsNegativeUnits tests that Reserve validates n like AllowN and Check
+func TestReserveRejectsNegativeUnits(t *testing.T) {
+	limiter := NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	limiter.ConfigurePolicy("export", 5, 60)
+	defer limiter.Close()
+
+	if _, err := limiter.Reserve(context.Background(), "export", []string{"tenant", "acme"}, -1); err == nil {
+		t.Fatal("Expected error for negative units")
+	}
+}
+
+// TestCancelKeepsReservationWhenRefundFails tests that a failed refund can be retried
+func TestCancelKeepsReservationWhenRefundFails(t *testing.T) {
+	memory := strategy.NewMemoryStorage()
+	storage := strategy.NewFaultStorage(memory, strategy.FaultConfig{})
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigurePolicy("export", 5, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	reservation, err := limiter.Reserve(ctx, "export", []string{"tenant", "acme"}, 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	storage.SetConfig(strategy.FaultConfig{ErrorRate: 1})
+	if err := reservation.Cancel(ctx); !errors.Is(err, ErrStorageUnavailable) {
+		t.Fatalf("Expected ErrStorageUnavailable, got %v", err)
+	}
+
+	storage.SetConfig(strategy.FaultConfig{})
+	if err := reservation.Cancel(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if counter, _ := memory.GetCounter(ctx, "limiter:policy:export:{tenant:acme}"); counter != 0 {
+		t.Fatalf("Expected units to be returned on retry, counter is %d", counter)
+	}
+}
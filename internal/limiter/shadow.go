@@ -0,0 +1,118 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ShadowStats counts the outcomes of a shadow policy
+type ShadowStats struct {
+	// Evaluated is the number of requests checked against the policy
+	Evaluated int64
+	// WouldDeny is the number of requests the policy would have denied
+	WouldDeny int64
+	// Compared is the number of requests also decided by the enforced limits,
+	// see CompareShadow
+	Compared int64
+	// NewlyDenied counts requests the enforced limits allowed and the policy would deny
+	NewlyDenied int64
+	// NewlyAllowed counts requests the enforced limits denied and the policy would allow
+	NewlyAllowed int64
+}
+
+type shadowCounters struct {
+	evaluated    atomic.Int64
+	wouldDeny    atomic.Int64
+	compared     atomic.Int64
+	newlyDenied  atomic.Int64
+	newlyAllowed atomic.Int64
+}
+
+// shadowRegistry holds the counters of every shadow policy
+type shadowRegistry struct {
+	mu       sync.Mutex
+	counters map[string]*shadowCounters
+	// log reports would-deny decisions without logging every request
+	log throttledLog
+}
+
+func (r *shadowRegistry) get(policy string) *shadowCounters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counters == nil {
+		r.counters = make(map[string]*shadowCounters)
+	}
+	counters, exists := r.counters[policy]
+	if !exists {
+		counters = &shadowCounters{}
+		r.counters[policy] = counters
+	}
+	return counters
+}
+
+// recordShadow counts a shadow decision and turns a denial into an allowed
+// request marked as ShadowDenied
+func (rl *RateLimiter) recordShadow(policy string, key string, decision *Decision) *shadowCounters {
+	counters := rl.shadow.get(policy)
+	counters.evaluated.Add(1)
+	if !decision.Allowed {
+		counters.wouldDeny.Add(1)
+		rl.shadow.log.Printf("rate limiter: shadow policy %s would have denied %s", policy, key)
+		decision.Allowed = true
+		decision.ShadowDenied = true
+	}
+	return counters
+}
+
+// CompareShadow checks the named policy in shadow mode for a request that was
+// already decided by the enforced limits, e.g. a tighter policy being rolled
+// out on a route. The outcome is only counted, it never affects the request.
+func (rl *RateLimiter) CompareShadow(ctx context.Context, policyName string, keyParts []string, cost int, enforcedAllowed bool) (Decision, error) {
+	policy, exists := rl.Policy(policyName)
+	if !exists {
+		return Decision{}, fmt.Errorf("%w: %s", ErrUnknownPolicy, policyName)
+	}
+	if cost < 0 {
+		return Decision{}, fmt.Errorf("invalid cost %d", cost)
+	}
+
+	ctx, cancel := rl.withTimeout(ctx)
+	defer cancel()
+
+	key := policyKey(policy.Name, keyParts)
+	decision, err := rl.decide(ctx, key, policy.Limit, policy.BlockDuration, cost)
+	if err != nil {
+		return decision, err
+	}
+
+	counters := rl.recordShadow(policy.Name, key, &decision)
+	counters.compared.Add(1)
+	switch {
+	case decision.ShadowDenied && enforcedAllowed:
+		counters.newlyDenied.Add(1)
+	case !decision.ShadowDenied && !enforcedAllowed:
+		counters.newlyAllowed.Add(1)
+	}
+	return decision, nil
+}
+
+// ShadowStats returns the counters of every policy evaluated in shadow mode
+func (rl *RateLimiter) ShadowStats() map[string]ShadowStats {
+	rl.shadow.mu.Lock()
+	defer rl.shadow.mu.Unlock()
+
+	stats := make(map[string]ShadowStats, len(rl.shadow.counters))
+	for policy, counters := range rl.shadow.counters {
+		stats[policy] = ShadowStats{
+			Evaluated:    counters.evaluated.Load(),
+			WouldDeny:    counters.wouldDeny.Load(),
+			Compared:     counters.compared.Load(),
+			NewlyDenied:  counters.newlyDenied.Load(),
+			NewlyAllowed: counters.newlyAllowed.Load(),
+		}
+	}
+	return stats
+}
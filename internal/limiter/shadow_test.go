@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestShadowPolicyNeverDenies tests that a shadow policy counts the requests
+// it would deny but allows them
+func TestShadowPolicyNeverDenies(t *testing.T) {
+	limiter := NewRateLimiter(NewMockStorage(), 5, 300)
+	limiter.ConfigureShadowPolicy("strict", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	if decision, _ := limiter.Check(ctx, "strict", []string{"tenant:a"}, 1); !decision.Allowed || decision.ShadowDenied {
+		t.Fatalf("First check should be allowed, got %+v", decision)
+	}
+	decision, err := limiter.Check(ctx, "strict", []string{"tenant:a"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !decision.Allowed || !decision.ShadowDenied {
+		t.Fatalf("Second check should be allowed and marked as shadow denied, got %+v", decision)
+	}
+
+	if stats := limiter.ShadowStats()["strict"]; stats != (ShadowStats{Evaluated: 2, WouldDeny: 1}) {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+}
+
+// TestCompareShadow tests that disagreements with the enforced decision are counted
+func TestCompareShadow(t *testing.T) {
+	limiter := NewRateLimiter(NewMockStorage(), 5, 300)
+	limiter.ConfigurePolicy("strict", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+
+	// Same outcome, then the enforced limits allow what the policy would deny
+	limiter.CompareShadow(ctx, "strict", []string{"token:a"}, 1, true)
+	if decision, _ := limiter.CompareShadow(ctx, "strict", []string{"token:a"}, 1, true); !decision.Allowed || !decision.ShadowDenied {
+		t.Fatalf("Shadow comparison should never deny, got %+v", decision)
+	}
+	// The enforced limits deny what the policy would allow
+	limiter.CompareShadow(ctx, "strict", []string{"token:b"}, 1, false)
+
+	expected := ShadowStats{Evaluated: 3, WouldDeny: 1, Compared: 3, NewlyDenied: 1, NewlyAllowed: 1}
+	if stats := limiter.ShadowStats()["strict"]; stats != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, stats)
+	}
+
+	if _, err := limiter.CompareShadow(ctx, "unknown", []string{"token:a"}, 1, true); err == nil {
+		t.Fatal("Expected error for an unknown policy")
+	}
+}
+
+// TestShadowDenialsAreNotLoggedPerRequest tests that would-deny decisions do not flood the log
+func TestShadowDenialsAreNotLoggedPerRequest(t *testing.T) {
+	var output bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&output)
+
+	limiter := NewRateLimiter(NewMockStorage(), 5, 300)
+	limiter.ConfigureShadowPolicy("strict", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		limiter.Check(ctx, "strict", []string{"tenant", "a"}, 1)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 1 {
+		t.Fatalf("Expected one log line, got:\n%s", output.String())
+	}
+	if stats := limiter.ShadowStats()["strict"]; stats.WouldDeny != 49 {
+		t.Fatalf("Every would-deny decision should still be counted, got %+v", stats)
+	}
+}
+
+// TestReserveShadowPolicyNeverWaits tests that reservations of a shadow policy are granted and counted
+func TestReserveShadowPolicyNeverWaits(t *testing.T) {
+	storage := NewMockStorage()
+	limiter := NewRateLimiter(storage, 5, 300)
+	limiter.ConfigureShadowPolicy("strict", 1, 60)
+	defer limiter.Close()
+
+	ctx := context.Background()
+	for _, n := range []int{1, 1, 2} {
+		reservation, err := limiter.Reserve(ctx, "strict", []string{"tenant", "a"}, n)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if delay := reservation.Delay(); delay != 0 {
+			t.Fatalf("Shadow policy should not delay, got %v", delay)
+		}
+		if err := reservation.Wait(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if stats := limiter.ShadowStats()["strict"]; stats.Evaluated != 3 || stats.WouldDeny != 2 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	if storage.counters["limiter:policy:strict:{tenant:a}"] != 1 {
+		t.Fatalf("Only units that fit should be consumed, got %v", storage.counters)
+	}
+}
@@ -0,0 +1,185 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// slowStorage answers every call after a delay longer than the decision timeout
+func slowStorage() *strategy.FaultStorage {
+	return strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{Latency: 500 * time.Millisecond})
+}
+
+// TestDecisionTimeoutFailClosed tests that a slow storage is cut off and reported as a timeout
+func TestDecisionTimeoutFailClosed(t *testing.T) {
+	limiter := NewRateLimiter(slowStorage(), 5, 60)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+	defer limiter.Close()
+
+	start := time.Now()
+	allowed, err := limiter.Allow(context.Background(), "192.168.1.1", "")
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected the decision to stop after the timeout, took %v", elapsed)
+	}
+	if allowed || !errors.Is(err, ErrStorageUnavailable) || !errors.Is(err, ErrStorageTimeout) {
+		t.Fatalf("Expected a denied request with a storage timeout, got %v, %v", allowed, err)
+	}
+	if failures := limiter.StorageFailures(); failures != (StorageFailures{Timeouts: 1}) {
+		t.Fatalf("Expected one timeout, got %+v", failures)
+	}
+}
+
+// TestDecisionTimeoutFailOpen tests that timeouts follow the failure mode
+func TestDecisionTimeoutFailOpen(t *testing.T) {
+	limiter := NewRateLimiter(slowStorage(), 5, 60)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+	limiter.SetFailureMode(FailOpen, nil)
+	defer limiter.Close()
+
+	allowed, err := limiter.Allow(context.Background(), "192.168.1.1", "")
+	if !allowed || err != nil {
+		t.Fatalf("Expected the request to be allowed, got %v, %v", allowed, err)
+	}
+	if limiter.StorageFailures().Timeouts != 1 {
+		t.Fatalf("Expected the timeout to be counted, got %+v", limiter.StorageFailures())
+	}
+}
+
+// TestDecisionTimeoutFailLocal tests that the local fallback still answers once the time is spent
+func TestDecisionTimeoutFailLocal(t *testing.T) {
+	limiter := NewRateLimiter(slowStorage(), 1, 60)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+	limiter.SetFailureMode(FailLocal, strategy.NewMemoryStorage())
+	defer limiter.Close()
+
+	ctx := context.Background()
+	if allowed, err := limiter.Allow(ctx, "192.168.1.1", ""); !allowed || err != nil {
+		t.Fatalf("Expected the first request to be allowed locally, got %v, %v", allowed, err)
+	}
+	if allowed, err := limiter.Allow(ctx, "192.168.1.1", ""); allowed || err != nil {
+		t.Fatalf("Expected the second request to be denied locally, got %v, %v", allowed, err)
+	}
+}
+
+// TestDecisionTimeoutPolicies tests that policy checks are bounded too
+func TestDecisionTimeoutPolicies(t *testing.T) {
+	limiter := NewRateLimiter(slowStorage(), 5, 60)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+	limiter.ConfigurePolicy("search", 10, 60)
+	defer limiter.Close()
+
+	if _, err := limiter.Check(context.Background(), "search", []string{"acme"}, 1); !errors.Is(err, ErrStorageTimeout) {
+		t.Fatalf("Expected a storage timeout, got %v", err)
+	}
+}
+
+// TestDecisionTimeoutConcurrency tests that acquiring and releasing slots are bounded too
+func TestDecisionTimeoutConcurrency(t *testing.T) {
+	storage := slowStorage()
+	limiter := newConcurrencyLimiter(t, storage, 1, time.Minute)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+
+	ctx := context.Background()
+	start := time.Now()
+	if _, err := limiter.Acquire(ctx, "*", "192.168.1.1", "", 0); !errors.Is(err, ErrStorageUnavailable) {
+		t.Fatalf("Expected the storage to be unavailable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected the acquire to stop after the timeout, took %v", elapsed)
+	}
+
+	storage.SetConfig(strategy.FaultConfig{})
+	slot, err := limiter.Acquire(ctx, "*", "192.168.1.1", "", 0)
+	if err != nil || slot == nil {
+		t.Fatalf("Expected a slot, got %v, %v", slot, err)
+	}
+	storage.SetConfig(strategy.FaultConfig{Latency: 500 * time.Millisecond})
+	start = time.Now()
+	if err := slot.Release(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the release to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("Expected the release to stop after the timeout, took %v", elapsed)
+	}
+}
+
+// TestQuotaRefundAfterTimeout tests that units taken from a shorter window
+// are returned even when a longer window runs out of time
+func TestQuotaRefundAfterTimeout(t *testing.T) {
+	now := clock.NewFake(clockStart)
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorageWithClock(now), strategy.FaultConfig{Latency: 100 * time.Millisecond})
+	limiter := NewRateLimiter(storage, 5, 60)
+	limiter.SetClock(now)
+	limiter.SetDecisionTimeout(150 * time.Millisecond)
+	if err := limiter.ConfigureQuotas("acme", QuotaWindow{Period: PeriodMinute, Limit: 10}, QuotaWindow{Period: PeriodHour, Limit: 100}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer limiter.Close()
+
+	ctx := context.Background()
+	if _, err := limiter.Allow(ctx, "192.168.1.1", "acme"); !errors.Is(err, ErrStorageTimeout) {
+		t.Fatalf("Expected a storage timeout, got %v", err)
+	}
+
+	storage.SetConfig(strategy.FaultConfig{})
+	usage, err := limiter.QuotaUsage(ctx, "acme")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, window := range usage {
+		if window.Used != 0 {
+			t.Fatalf("Expected the %s window to be refunded, got %+v", window.Period, window)
+		}
+	}
+}
+
+// TestStorageErrorsAreNotTimeouts tests that other failures are counted apart
+func TestStorageErrorsAreNotTimeouts(t *testing.T) {
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{ErrorRate: 1})
+	limiter := NewRateLimiter(storage, 5, 60)
+	limiter.SetDecisionTimeout(20 * time.Millisecond)
+	defer limiter.Close()
+
+	_, err := limiter.Allow(context.Background(), "192.168.1.1", "")
+	if !errors.Is(err, ErrStorageUnavailable) || errors.Is(err, ErrStorageTimeout) {
+		t.Fatalf("Expected a storage error that is not a timeout, got %v", err)
+	}
+	if failures := limiter.StorageFailures(); failures != (StorageFailures{Errors: 1}) {
+		t.Fatalf("Expected one error, got %+v", failures)
+	}
+}
+
+// TestNoDecisionTimeout tests that without a timeout a slow storage still answers
+func TestNoDecisionTimeout(t *testing.T) {
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{Latency: 10 * time.Millisecond})
+	limiter := NewRateLimiter(storage, 5, 60)
+	defer limiter.Close()
+
+	if allowed, err := limiter.Allow(context.Background(), "192.168.1.1", ""); !allowed || err != nil {
+		t.Fatalf("Expected the request to be allowed, got %v, %v", allowed, err)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	if !isTimeout(context.DeadlineExceeded) {
+		t.Fatal("Expected DeadlineExceeded to be a timeout")
+	}
+	if !isTimeout(&net.OpError{Op: "read", Err: timeoutError{}}) {
+		t.Fatal("Expected a network timeout to be a timeout")
+	}
+	if isTimeout(context.Canceled) || isTimeout(errors.New("connection refused")) {
+		t.Fatal("Expected cancellations and other errors not to be timeouts")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
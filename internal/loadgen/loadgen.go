@@ -0,0 +1,214 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Distributions of clients across requests
+const (
+	DistUniform = "uniform"
+	DistZipf    = "zipf"
+)
+
+// Config describes the load to generate
+type Config struct {
+	// URL is requested with GET
+	URL string
+	// RPS is the target request rate. Requests are scheduled at a fixed pace
+	// whatever the latency; when every worker is busy the request is dropped
+	// and counted, so a slow server cannot hide behind a lower rate.
+	RPS         int
+	Duration    time.Duration
+	Concurrency int
+
+	// IPs is the number of distinct client IPs, sent in X-Forwarded-For
+	IPs    int
+	IPDist string
+	// Tokens are sent in the API_KEY header on a TokenRatio share of the requests
+	Tokens     []string
+	TokenRatio float64
+	TokenDist  string
+	// ZipfS is the Zipf exponent, larger values send more traffic to the top clients
+	ZipfS float64
+
+	Seed int64
+}
+
+// Validate checks the configuration before running it
+func (c Config) Validate() error {
+	switch {
+	case c.URL == "":
+		return fmt.Errorf("url is required")
+	case c.RPS <= 0 || c.Duration <= 0 || c.Concurrency <= 0:
+		return fmt.Errorf("rps, duration and concurrency must be positive")
+	case c.IPs <= 0:
+		return fmt.Errorf("ips must be positive")
+	case c.TokenRatio < 0 || c.TokenRatio > 1:
+		return fmt.Errorf("token ratio must be between 0 and 1")
+	case c.TokenRatio > 0 && len(c.Tokens) == 0:
+		return fmt.Errorf("tokens are required with a token ratio")
+	}
+	for _, dist := range []string{c.IPDist, c.TokenDist} {
+		if dist != DistUniform && dist != DistZipf {
+			return fmt.Errorf("invalid distribution %q (expected uniform or zipf)", dist)
+		}
+		if dist == DistZipf && c.ZipfS <= 1 {
+			return fmt.Errorf("zipf exponent must be greater than 1")
+		}
+	}
+	return nil
+}
+
+// picker returns indexes in [0, n) following a distribution, it is not safe
+// for concurrent use
+type picker func() int
+
+func newPicker(r *rand.Rand, dist string, n int, s float64) picker {
+	if dist == DistZipf && n > 1 {
+		zipf := rand.NewZipf(r, s, 1, uint64(n-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+	return func() int { return r.Intn(n) }
+}
+
+type request struct {
+	ip    string
+	token string
+}
+
+// Result holds the outcome of a run
+type Result struct {
+	Scheduled int
+	// Dropped requests were scheduled while every worker was busy
+	Dropped int
+	// Errors are requests that got no response
+	Errors    int
+	Status    map[int]int
+	Latencies []time.Duration
+	Elapsed   time.Duration
+}
+
+// Completed is the number of requests that got a response
+func (r *Result) Completed() int {
+	return len(r.Latencies)
+}
+
+// Percentile returns the latency under which p percent of the responses completed
+func (r *Result) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	index := int(float64(len(r.Latencies))*p/100+0.5) - 1
+	return r.Latencies[min(max(index, 0), len(r.Latencies)-1)]
+}
+
+// DeniedRate is the share of responses that were 429
+func (r *Result) DeniedRate() float64 {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	return float64(r.Status[http.StatusTooManyRequests]) / float64(len(r.Latencies))
+}
+
+// Run sends the configured load until the duration elapses or ctx is done,
+// then waits for the requests in flight
+func Run(ctx context.Context, config Config, client *http.Client) (*Result, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := rand.New(rand.NewSource(config.Seed))
+	pickIP := newPicker(r, config.IPDist, config.IPs, config.ZipfS)
+	pickToken := newPicker(r, config.TokenDist, max(len(config.Tokens), 1), config.ZipfS)
+
+	result := &Result{Status: make(map[int]int)}
+	var mu sync.Mutex
+
+	jobs := make(chan request)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				latency, status, err := send(client, config.URL, job)
+
+				mu.Lock()
+				if err != nil {
+					result.Errors++
+				} else {
+					result.Status[status]++
+					result.Latencies = append(result.Latencies, latency)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	interval := time.Second / time.Duration(config.RPS)
+	start := time.Now()
+	deadline := start.Add(config.Duration)
+schedule:
+	for next := start; next.Before(deadline); next = next.Add(interval) {
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-ctx.Done():
+				break schedule
+			case <-time.After(wait):
+			}
+		}
+
+		job := request{ip: ipAddress(pickIP())}
+		if config.TokenRatio > 0 && r.Float64() < config.TokenRatio {
+			job.token = config.Tokens[pickToken()]
+		}
+
+		result.Scheduled++
+		select {
+		case jobs <- job:
+		default:
+			result.Dropped++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.Elapsed = time.Since(start)
+	sort.Slice(result.Latencies, func(i, j int) bool {
+		return result.Latencies[i] < result.Latencies[j]
+	})
+	return result, nil
+}
+
+func send(client *http.Client, url string, job request) (time.Duration, int, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("X-Forwarded-For", job.ip)
+	if job.token != "" {
+		req.Header.Set("API_KEY", job.token)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Drain the body so the connection is reused
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return time.Since(start), resp.StatusCode, nil
+}
+
+// ipAddress maps a client index to an address in 10.0.0.0/8
+func ipAddress(i int) string {
+	return fmt.Sprintf("10.%d.%d.%d", i>>16&255, i>>8&255, i&255)
+}
@@ -0,0 +1,153 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testConfig(url string) Config {
+	return Config{
+		URL:         url,
+		RPS:         200,
+		Duration:    200 * time.Millisecond,
+		Concurrency: 4,
+		IPs:         10,
+		IPDist:      DistUniform,
+		Tokens:      []string{"token123"},
+		TokenRatio:  0.5,
+		TokenDist:   DistUniform,
+		ZipfS:       1.1,
+		Seed:        1,
+	}
+}
+
+// TestRun tests that requests reach the server with client headers and that
+// 429 responses are counted
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	ips := make(map[string]bool)
+	var withToken, requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		ips[r.Header.Get("X-Forwarded-For")] = true
+		if r.Header.Get("API_KEY") == "token123" {
+			withToken++
+		}
+		if requests%4 == 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Run(context.Background(), testConfig(server.URL), server.Client())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if result.Scheduled < 30 || result.Completed()+result.Dropped+result.Errors != result.Scheduled {
+		t.Fatalf("Unexpected counts %+v", result)
+	}
+	if result.Status[http.StatusOK]+result.Status[http.StatusTooManyRequests] != result.Completed() {
+		t.Fatalf("Unexpected statuses %v", result.Status)
+	}
+	if rate := result.DeniedRate(); rate < 0.2 || rate > 0.3 {
+		t.Fatalf("Expected about a quarter of 429s, got %.2f", rate)
+	}
+	if len(ips) < 5 || withToken == 0 || withToken == requests {
+		t.Fatalf("Expected several IPs and part of the requests with a token, got %d IPs and %d/%d tokens", len(ips), withToken, requests)
+	}
+
+	var report bytes.Buffer
+	WriteReport(&report, result)
+	for _, expected := range []string{"429 rate:", "200=", "p99="} {
+		if !strings.Contains(report.String(), expected) {
+			t.Fatalf("Report is missing %q:\n%s", expected, report.String())
+		}
+	}
+}
+
+// TestRunDropsWhenWorkersAreBusy tests that the pace is kept when the server is slow
+func TestRunDropsWhenWorkersAreBusy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	config := testConfig(server.URL)
+	config.Concurrency = 1
+	result, err := Run(context.Background(), config, server.Client())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Dropped == 0 || result.Completed() > 3 {
+		t.Fatalf("Expected dropped requests with a single slow worker, got %+v", result)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(*Config){
+		"no url":        func(c *Config) { c.URL = "" },
+		"no rps":        func(c *Config) { c.RPS = 0 },
+		"no ips":        func(c *Config) { c.IPs = 0 },
+		"ratio":         func(c *Config) { c.TokenRatio = 1.5 },
+		"no tokens":     func(c *Config) { c.Tokens = nil },
+		"distribution":  func(c *Config) { c.IPDist = "normal" },
+		"zipf exponent": func(c *Config) { c.TokenDist, c.ZipfS = DistZipf, 1 },
+	}
+	for name, mutate := range tests {
+		config := testConfig("http://localhost")
+		mutate(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if err := testConfig("http://localhost").Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// TestZipfPicker tests that the Zipf distribution concentrates traffic on the first clients
+func TestZipfPicker(t *testing.T) {
+	share := func(dist string) float64 {
+		pick := newPicker(rand.New(rand.NewSource(1)), dist, 1000, 1.1)
+		var top int
+		for i := 0; i < 10000; i++ {
+			if index := pick(); index < 10 {
+				top++
+			} else if index >= 1000 {
+				t.Fatalf("Index %d out of range", index)
+			}
+		}
+		return float64(top) / 10000
+	}
+
+	uniform, zipf := share(DistUniform), share(DistZipf)
+	if uniform > 0.05 || zipf < 0.3 {
+		t.Fatalf("Expected the top 10 clients to get ~1%% of uniform and a large share of Zipf traffic, got %.2f and %.2f", uniform, zipf)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	result := &Result{}
+	if result.Percentile(99) != 0 {
+		t.Fatal("Expected zero without responses")
+	}
+	for i := 1; i <= 100; i++ {
+		result.Latencies = append(result.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, expected := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := result.Percentile(p); got != expected {
+			t.Errorf("p%g: expected %v, got %v", p, expected, got)
+		}
+	}
+}
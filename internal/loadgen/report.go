@@ -0,0 +1,42 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// WriteReport writes the throughput, status codes and latency percentiles of a run
+func WriteReport(w io.Writer, result *Result) {
+	seconds := result.Elapsed.Seconds()
+	if seconds == 0 {
+		seconds = 1
+	}
+
+	fmt.Fprintf(w, "Duration:   %v\n", result.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Requests:   %d scheduled, %d completed (%.1f/s), %d dropped, %d errors\n",
+		result.Scheduled, result.Completed(), float64(result.Completed())/seconds, result.Dropped, result.Errors)
+	fmt.Fprintf(w, "429 rate:   %.2f%%\n", result.DeniedRate()*100)
+
+	statuses := make([]int, 0, len(result.Status))
+	for status := range result.Status {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	fmt.Fprint(w, "Status:    ")
+	for _, status := range statuses {
+		fmt.Fprintf(w, " %d=%d", status, result.Status[status])
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprint(w, "Latency:   ")
+	for _, p := range []float64{50, 90, 99, 99.9, 100} {
+		label := fmt.Sprintf("p%g", p)
+		if p == 100 {
+			label = "max"
+		}
+		fmt.Fprintf(w, " %s=%v", label, result.Percentile(p).Round(time.Microsecond))
+	}
+	fmt.Fprintln(w)
+}
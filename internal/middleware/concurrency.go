@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// ConcurrencyRule limits in-flight requests per client on a route prefix
+type ConcurrencyRule struct {
+	Prefix string
+	Limit  int
+}
+
+// ParseConcurrencyRules parses a comma separated list of /prefix=limit entries,
+// e.g. "/api/reports/=2,/api/=10"
+func ParseConcurrencyRules(value string) ([]ConcurrencyRule, error) {
+	var rules []ConcurrencyRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, limit, found := strings.Cut(entry, "=")
+		parsed, err := strconv.Atoi(limit)
+		if !found || !strings.HasPrefix(prefix, "/") || err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid concurrency rule %q (expected /prefix=limit)", entry)
+		}
+		rules = append(rules, ConcurrencyRule{Prefix: prefix, Limit: parsed})
+	}
+	return rules, nil
+}
+
+// ConcurrencyMiddleware caps in-flight requests per client. Each route rule
+// has its own slots (longest prefix wins); other requests share the default
+// limit. The slot is released when the handler returns or panics.
+func ConcurrencyMiddleware(cl *limiter.ConcurrencyLimiter, rules []ConcurrencyRule) func(http.Handler) http.Handler {
+	sorted := make([]ConcurrencyRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, routeLimit := "*", 0
+			for _, rule := range sorted {
+				if strings.HasPrefix(r.URL.Path, rule.Prefix) {
+					scope, routeLimit = rule.Prefix, rule.Limit
+					break
+				}
+			}
+
+			slot, err := cl.Acquire(r.Context(), scope, getClientIP(r), r.Header.Get("API_KEY"), routeLimit)
+			if err != nil {
+				if errors.Is(err, limiter.ErrStorageUnavailable) {
+					http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if slot == nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"error":"you have reached the maximum number of concurrent requests allowed"}`))
+				return
+			}
+
+			// The request context may already be canceled, release with a fresh one
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := slot.Release(ctx); err != nil {
+					log.Printf("concurrency limiter: failed to release slot: %v", err)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// newConcurrencyLimiter creates a concurrency limiter, failing the test on error
+func newConcurrencyLimiter(t *testing.T, storage strategy.StorageStrategy, defaultLimit int, lease time.Duration) *limiter.ConcurrencyLimiter {
+	t.Helper()
+	cl, err := limiter.NewConcurrencyLimiter(storage, defaultLimit, lease)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return cl
+}
+
+// TestParseConcurrencyRules tests parsing of the concurrency rule configuration
+func TestParseConcurrencyRules(t *testing.T) {
+	rules, err := ParseConcurrencyRules("/api/reports/=2, /api/=10")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (ConcurrencyRule{Prefix: "/api/reports/", Limit: 2}) || rules[1].Limit != 10 {
+		t.Fatalf("Unexpected rules %+v", rules)
+	}
+
+	for _, invalid := range []string{"/api", "api=1", "/api=0", "/api=x"} {
+		if _, err := ParseConcurrencyRules(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestConcurrencyMiddleware tests that in-flight requests are capped and
+// slots are released when the handler completes or panics
+func TestConcurrencyMiddleware(t *testing.T) {
+	cl := newConcurrencyLimiter(t, strategy.NewMemoryStorage(), 0, time.Minute)
+	rules := []ConcurrencyRule{{Prefix: "/api/reports/", Limit: 1}}
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := ConcurrencyMiddleware(cl, rules)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/reports/slow":
+			entered <- struct{}{}
+			<-unblock
+		case "/api/reports/panic":
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/api/reports/slow") }()
+	<-entered
+
+	if code := serve("/api/reports/fast"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 while a request is in flight, got %d", code)
+	}
+	if code := serve("/other"); code != http.StatusOK {
+		t.Fatalf("Routes without a limit should not be affected, got %d", code)
+	}
+
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("Expected in-flight request to complete, got %d", code)
+	}
+	if code := serve("/api/reports/fast"); code != http.StatusOK {
+		t.Fatalf("Slot should be released after completion, got %d", code)
+	}
+
+	func() {
+		defer func() { recover() }()
+		serve("/api/reports/panic")
+	}()
+	if code := serve("/api/reports/fast"); code != http.StatusOK {
+		t.Fatalf("Slot should be released after a panic, got %d", code)
+	}
+}
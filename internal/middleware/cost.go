@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CostFunc returns how many units a request consumes
+type CostFunc func(r *http.Request) int
+
+// CostRule sets the cost of requests whose path starts with Prefix and,
+// when Method is set, use that method
+type CostRule struct {
+	Method string
+	Prefix string
+	Cost   int
+}
+
+// ParseCostRules parses a comma separated list of [METHOD ]/prefix=cost entries,
+// e.g. "POST /api/export=50,/health=0"
+func ParseCostRules(value string) ([]CostRule, error) {
+	var rules []CostRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var rule CostRule
+		route, cost, found := strings.Cut(entry, "=")
+		if method, prefix, hasMethod := strings.Cut(route, " "); hasMethod {
+			rule.Method = strings.ToUpper(method)
+			route = strings.TrimSpace(prefix)
+		}
+		parsed, err := strconv.Atoi(cost)
+		if !found || !strings.HasPrefix(route, "/") || err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid cost rule %q (expected [METHOD ]/prefix=cost)", entry)
+		}
+
+		rule.Prefix = route
+		rule.Cost = parsed
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// RouteCost returns the cost of the longest matching rule, rules with a
+// method win over rules without one. Unmatched requests use fallback, or
+// cost 1 when fallback is nil.
+func RouteCost(rules []CostRule, fallback CostFunc) CostFunc {
+	sorted := make([]CostRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Prefix) != len(sorted[j].Prefix) {
+			return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+		}
+		return sorted[i].Method != "" && sorted[j].Method == ""
+	})
+
+	return func(r *http.Request) int {
+		for _, rule := range sorted {
+			if strings.HasPrefix(r.URL.Path, rule.Prefix) && (rule.Method == "" || rule.Method == r.Method) {
+				return rule.Cost
+			}
+		}
+		return costOrDefault(fallback, r)
+	}
+}
+
+// HeaderCost reads the cost from a request header. Only use it when the header
+// is set by trusted upstreams and stripped from client requests. Missing or
+// invalid values use fallback, or cost 1 when fallback is nil.
+func HeaderCost(header string, fallback CostFunc) CostFunc {
+	return func(r *http.Request) int {
+		if cost, err := strconv.Atoi(r.Header.Get(header)); err == nil && cost >= 0 {
+			return cost
+		}
+		return costOrDefault(fallback, r)
+	}
+}
+
+func costOrDefault(cost CostFunc, r *http.Request) int {
+	if cost == nil {
+		return 1
+	}
+	return cost(r)
+}
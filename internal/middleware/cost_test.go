@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// TestParseCostRules tests parsing of the cost rule configuration
+func TestParseCostRules(t *testing.T) {
+	rules, err := ParseCostRules("post /api/export=50, /health=0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (CostRule{Method: "POST", Prefix: "/api/export", Cost: 50}) || rules[1].Cost != 0 {
+		t.Fatalf("Unexpected rules %+v", rules)
+	}
+
+	for _, invalid := range []string{"/api", "api=1", "/api=-1", "/api=x"} {
+		if _, err := ParseCostRules(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestCostFuncs tests route rules, method precedence and the trusted header
+func TestCostFuncs(t *testing.T) {
+	rules, _ := ParseCostRules("/api/=2,POST /api/export=50,/api/export=10,/health=0")
+	cost := HeaderCost("X-Request-Cost", RouteCost(rules, nil))
+
+	cases := []struct {
+		method string
+		path   string
+		header string
+		want   int
+	}{
+		{"POST", "/api/export/monthly", "", 50},
+		{"GET", "/api/export", "", 10},
+		{"GET", "/api/users", "", 2},
+		{"GET", "/health", "", 0},
+		{"GET", "/other", "", 1},
+		{"GET", "/api/users", "7", 7},
+		{"GET", "/api/users", "invalid", 2},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.header != "" {
+			req.Header.Set("X-Request-Cost", c.header)
+		}
+		if got := cost(req); got != c.want {
+			t.Fatalf("%s %s (header %q): expected cost %d, got %d", c.method, c.path, c.header, c.want, got)
+		}
+	}
+}
+
+// TestRateLimiterMiddlewareWithCost tests that expensive requests consume several units
+func TestRateLimiterMiddlewareWithCost(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(NewMockStorage(), 5, 300)
+	defer rateLimiter.Close()
+
+	rules, _ := ParseCostRules("/api/export=3,/health=0")
+	handler := RateLimiterMiddlewareWithCost(rateLimiter, RouteCost(rules, nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-For", "192.168.1.1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send("/api/export"); code != http.StatusOK {
+		t.Fatalf("First export should be allowed, got %d", code)
+	}
+	// 3 of 5 units used, another export does not fit
+	if code := send("/api/export"); code != http.StatusTooManyRequests {
+		t.Fatalf("Second export should be denied, got %d", code)
+	}
+	if code := send("/health"); code != http.StatusOK {
+		t.Fatalf("Zero cost request should be allowed, got %d", code)
+	}
+	if code := send("/api/users"); code != http.StatusOK {
+		t.Fatalf("Cheap request should still fit, got %d", code)
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// ForwardAuthHandler answers nginx auth_request and Traefik/Caddy forward-auth
+// subrequests. The original request is never proxied: the client IP, URI and
+// API_KEY are read from the subrequest headers, the limiter decides, and the
+// response carries X-RateLimit-* headers the proxy can copy back to the client.
+// Allowed requests get 200, denied ones get denyStatus (429, or 403 for nginx,
+// which only accepts 2xx, 401 and 403 from auth_request).
+func ForwardAuthHandler(rl *limiter.RateLimiter, denyStatus int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := originalClientIP(r)
+		token := r.Header.Get("API_KEY")
+
+		decision, err := rl.Decide(r.Context(), ip, token)
+		if err != nil {
+			if errors.Is(err, limiter.ErrStorageUnavailable) {
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(decision.Reset.Seconds())))
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("X-RateLimit-Reset", reset)
+
+		if !decision.Allowed {
+			log.Printf("forward-auth: denied %s %s from %s", originalMethod(r), originalURI(r), ip)
+			w.Header().Set("Retry-After", reset)
+			w.WriteHeader(denyStatus)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// originalClientIP prefers the forwarded chain of the original request, as set
+// by nginx with X-Original-Forwarded-For, over the subrequest's own headers
+func originalClientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Original-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	return getClientIP(r)
+}
+
+// originalURI returns the URI of the original request (nginx or Traefik/Caddy header)
+func originalURI(r *http.Request) string {
+	if uri := r.Header.Get("X-Original-URI"); uri != "" {
+		return uri
+	}
+	if uri := r.Header.Get("X-Forwarded-Uri"); uri != "" {
+		return uri
+	}
+	return r.URL.RequestURI()
+}
+
+// originalMethod returns the method of the original request (nginx or Traefik/Caddy header)
+func originalMethod(r *http.Request) string {
+	if method := r.Header.Get("X-Original-Method"); method != "" {
+		return method
+	}
+	if method := r.Header.Get("X-Forwarded-Method"); method != "" {
+		return method
+	}
+	return r.Method
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// TestForwardAuthHandler tests allowed and denied subrequests and the rate limit headers
+func TestForwardAuthHandler(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(NewMockStorage(), 2, 60)
+	defer rateLimiter.Close()
+
+	handler := ForwardAuthHandler(rateLimiter, http.StatusTooManyRequests)
+
+	subrequest := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/forward-auth", nil)
+		req.RemoteAddr = "127.0.0.1:4000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		req.Header.Set("X-Forwarded-Uri", "/api/orders?page=2")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := subrequest()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" || w.Header().Get("X-RateLimit-Reset") != "60" {
+		t.Fatalf("Unexpected headers %v", w.Header())
+	}
+
+	subrequest()
+
+	w = subrequest()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("Unexpected headers %v", w.Header())
+	}
+}
+
+// TestForwardAuthHandlerOriginalHeaders tests nginx X-Original-* headers, tokens and the deny status
+func TestForwardAuthHandlerOriginalHeaders(t *testing.T) {
+	storage := NewMockStorage()
+	rateLimiter := limiter.NewRateLimiter(storage, 1, 60)
+	rateLimiter.ConfigureToken("premium", 10, 60)
+	defer rateLimiter.Close()
+
+	handler := ForwardAuthHandler(rateLimiter, http.StatusForbidden)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/forward-auth", nil)
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		req.Header.Set("X-Original-Forwarded-For", "198.51.100.4, 10.0.0.1")
+		req.Header.Set("X-Original-URI", "/api/orders")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if i == 1 && w.Code != http.StatusForbidden {
+			t.Fatalf("Expected status 403, got %d", w.Code)
+		}
+	}
+	if storage.counters["limiter:ip:{198.51.100.4}"] != 1 {
+		t.Fatalf("Original client IP should be used, got %v", storage.counters)
+	}
+
+	req := httptest.NewRequest("GET", "/forward-auth", nil)
+	req.Header.Set("X-Original-Forwarded-For", "198.51.100.4")
+	req.Header.Set("API_KEY", "premium")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "10" {
+		t.Fatalf("Token should take precedence, got %d %v", w.Code, w.Header())
+	}
+}
+
+// TestForwardAuthHandlerStorageUnavailable tests that storage outages return 503
+func TestForwardAuthHandlerStorageUnavailable(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(&FailingStorage{}, 5, 300)
+	defer rateLimiter.Close()
+
+	w := httptest.NewRecorder()
+	ForwardAuthHandler(rateLimiter, http.StatusTooManyRequests).ServeHTTP(w, httptest.NewRequest("GET", "/forward-auth", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+}
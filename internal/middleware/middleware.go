@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net"
 	"net/http"
 	"strings"
@@ -10,6 +12,21 @@ import (
 
 // RateLimiterMiddleware returns a middleware function for rate limiting
 func RateLimiterMiddleware(rl *limiter.RateLimiter) func(http.Handler) http.Handler {
+	return RateLimiterMiddlewareWithCost(rl, nil)
+}
+
+// RateLimiterMiddlewareWithCost is like RateLimiterMiddleware but each request
+// consumes the units returned by cost (1 when cost is nil)
+func RateLimiterMiddlewareWithCost(rl *limiter.RateLimiter, cost CostFunc) func(http.Handler) http.Handler {
+	return RateLimiterMiddlewareWithShadow(rl, cost, nil)
+}
+
+// RateLimiterMiddlewareWithShadow is like RateLimiterMiddlewareWithCost and
+// also checks the shadow policy of the matching rule, comparing it with the
+// enforced decision. Shadow policies never deny and their errors are ignored.
+func RateLimiterMiddlewareWithShadow(rl *limiter.RateLimiter, cost CostFunc, shadowRules []ShadowRule) func(http.Handler) http.Handler {
+	shadowPolicy := matchShadowRule(shadowRules)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract IP address from request
@@ -19,8 +36,21 @@ func RateLimiterMiddleware(rl *limiter.RateLimiter) func(http.Handler) http.Hand
 			token := r.Header.Get("API_KEY")
 
 			// Check if request is allowed
-			allowed, err := rl.Allow(r.Context(), ip, token)
+			units := costOrDefault(cost, r)
+			allowed, err := rl.AllowN(r.Context(), ip, token, units)
+			if err == nil {
+				if policy := shadowPolicy(r.URL.Path); policy != "" {
+					if _, shadowErr := rl.CompareShadow(r.Context(), policy, shadowKey(ip, token), units, allowed); shadowErr != nil {
+						log.Printf("rate limiter: shadow policy %s failed: %v", policy, shadowErr)
+					}
+				}
+			}
 			if err != nil {
+				// Storage outages are reported as 503 so clients can retry later
+				if errors.Is(err, limiter.ErrStorageUnavailable) {
+					http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+					return
+				}
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
@@ -3,11 +3,13 @@ package middleware
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
 )
 
 // MockStorage for testing
@@ -206,3 +208,72 @@ func TestRateLimiterMiddlewareExtractsIPFromXForwardedFor(t *testing.T) {
 		t.Fatalf("First request from different IP should be allowed, got status %d", w3.Code)
 	}
 }
+
+// FailingStorage simulates a storage outage
+type FailingStorage struct {
+	MockStorage
+}
+
+func (f *FailingStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	return 0, errors.New("storage down")
+}
+
+// TestRateLimiterMiddlewareStorageUnavailable tests that storage outages return 503 when failing closed
+func TestRateLimiterMiddlewareStorageUnavailable(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(&FailingStorage{}, 5, 300)
+	defer rateLimiter.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrappedHandler := RateLimiterMiddleware(rateLimiter)(handler)
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-Forwarded-For", "192.168.1.1")
+	w := httptest.NewRecorder()
+	wrappedHandler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+}
+
+// TestRateLimiterMiddlewareDegradedStorage tests that a flaky storage only
+// turns into 503s when failing closed and is invisible when failing open
+func TestRateLimiterMiddlewareDegradedStorage(t *testing.T) {
+	for _, mode := range []limiter.FailureMode{limiter.FailClosed, limiter.FailOpen} {
+		storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{
+			ErrorRate:     0.3,
+			LostReplyRate: 0.1,
+			Seed:          1,
+		})
+		rateLimiter := limiter.NewRateLimiter(storage, 1000, 300)
+		rateLimiter.SetFailureMode(mode, nil)
+
+		handler := RateLimiterMiddleware(rateLimiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		statuses := make(map[int]int)
+		for i := 0; i < 200; i++ {
+			req := httptest.NewRequest("GET", "/api/test", nil)
+			req.Header.Set("X-Forwarded-For", "192.168.1.1")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			statuses[w.Code]++
+		}
+		rateLimiter.Close()
+
+		switch mode {
+		case limiter.FailClosed:
+			if statuses[http.StatusServiceUnavailable] == 0 || statuses[http.StatusOK]+statuses[http.StatusServiceUnavailable] != 200 {
+				t.Fatalf("Expected only 200s and 503s when failing closed, got %v", statuses)
+			}
+		case limiter.FailOpen:
+			if statuses[http.StatusOK] != 200 {
+				t.Fatalf("Expected only 200s when failing open, got %v", statuses)
+			}
+		}
+	}
+}
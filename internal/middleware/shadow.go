@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// ShadowRule runs a policy in shadow mode for requests whose path starts with Prefix
+type ShadowRule struct {
+	Prefix string
+	Policy string
+}
+
+// ParseShadowRules parses a comma separated list of /prefix=policy entries,
+// e.g. "/api/search=strict-search"
+func ParseShadowRules(value string) ([]ShadowRule, error) {
+	var rules []ShadowRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, policy, found := strings.Cut(entry, "=")
+		if !found || !strings.HasPrefix(prefix, "/") || policy == "" {
+			return nil, fmt.Errorf("invalid shadow rule %q (expected /prefix=policy)", entry)
+		}
+		rules = append(rules, ShadowRule{Prefix: prefix, Policy: policy})
+	}
+	return rules, nil
+}
+
+// matchShadowRule returns a lookup of the shadow policy for a path, the
+// longest matching prefix wins
+func matchShadowRule(rules []ShadowRule) func(path string) string {
+	sorted := make([]ShadowRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	return func(path string) string {
+		for _, rule := range sorted {
+			if strings.HasPrefix(path, rule.Prefix) {
+				return rule.Policy
+			}
+		}
+		return ""
+	}
+}
+
+// shadowKey identifies the client in a shadow policy the same way the enforced limits do
+func shadowKey(ip string, token string) []string {
+	if token != "" {
+		return []string{"token:" + token}
+	}
+	return []string{"ip:" + ip}
+}
+
+// ShadowStatsHandler reports the counters of every shadow policy as JSON
+func ShadowStatsHandler(rl *limiter.RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type policyStats struct {
+			Evaluated    int64 `json:"evaluated"`
+			WouldDeny    int64 `json:"would_deny"`
+			Compared     int64 `json:"compared"`
+			NewlyDenied  int64 `json:"newly_denied"`
+			NewlyAllowed int64 `json:"newly_allowed"`
+		}
+
+		response := make(map[string]policyStats)
+		for policy, stats := range rl.ShadowStats() {
+			response[policy] = policyStats(stats)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
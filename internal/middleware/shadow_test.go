@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// TestParseShadowRules tests parsing of the shadow rule configuration
+func TestParseShadowRules(t *testing.T) {
+	rules, err := ParseShadowRules("/api/search=strict, /api/=relaxed")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (ShadowRule{Prefix: "/api/search", Policy: "strict"}) || rules[1].Policy != "relaxed" {
+		t.Fatalf("Unexpected rules %+v", rules)
+	}
+
+	for _, invalid := range []string{"/api", "api=strict", "/api="} {
+		if _, err := ParseShadowRules(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestShadowMiddleware tests that the shadow policy of a route is compared
+// with the enforced limits without denying requests
+func TestShadowMiddleware(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 3, 60)
+	rl.ConfigurePolicy("strict", 1, 60)
+	defer rl.Close()
+
+	rules := []ShadowRule{{Prefix: "/api/search", Policy: "strict"}}
+	handler := RateLimiterMiddlewareWithShadow(rl, nil, rules)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve("/api/search"); code != http.StatusOK {
+			t.Fatalf("Request %d should be allowed by the enforced limit, got %d", i+1, code)
+		}
+	}
+	serve("/api/other")
+	if code := serve("/api/search"); code != http.StatusTooManyRequests {
+		t.Fatalf("Enforced limit should deny, got %d", code)
+	}
+
+	rr := httptest.NewRecorder()
+	ShadowStatsHandler(rl).ServeHTTP(rr, httptest.NewRequest("GET", "/shadow", nil))
+	var stats map[string]map[string]int64
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]int64{"evaluated": 3, "would_deny": 2, "compared": 3, "newly_denied": 1, "newly_allowed": 0}
+	for field, want := range expected {
+		if stats["strict"][field] != want {
+			t.Fatalf("Expected %s=%d, got %v", field, want, stats)
+		}
+	}
+}
+
+// TestShadowMiddlewareWithConcurrency tests that shadow policies are still
+// evaluated when the concurrency limit runs behind the rate limit, as wired in main
+func TestShadowMiddlewareWithConcurrency(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 10, 60)
+	rl.ConfigurePolicy("strict", 1, 60)
+	defer rl.Close()
+	cl := newConcurrencyLimiter(t, strategy.NewMemoryStorage(), 1, time.Minute)
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	concurrency := ConcurrencyMiddleware(cl, nil)
+	rules := []ShadowRule{{Prefix: "/api/search", Policy: "strict"}}
+	handler := RateLimiterMiddlewareWithShadow(rl, nil, rules)(concurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/api/search?slow=1") }()
+	<-entered
+	if code := serve("/api/search"); code != http.StatusTooManyRequests {
+		t.Fatalf("Concurrency limit should deny the second request, got %d", code)
+	}
+	close(unblock)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("First request should be allowed, got %d", code)
+	}
+
+	if stats := rl.ShadowStats()["strict"]; stats.Evaluated != 2 || stats.WouldDeny != 1 {
+		t.Fatalf("Expected both requests to be evaluated by the shadow policy, got %+v", stats)
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// StorageFailuresHandler reports the number of decisions the storage failed
+// to answer as JSON, timeouts counted apart from other errors
+func StorageFailuresHandler(rl *limiter.RateLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failures := rl.StorageFailures()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Timeouts int64 `json:"timeouts"`
+			Errors   int64 `json:"errors"`
+		}{failures.Timeouts, failures.Errors})
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+func TestStorageFailuresHandler(t *testing.T) {
+	storage := strategy.NewFaultStorage(strategy.NewMemoryStorage(), strategy.FaultConfig{Latency: 500 * time.Millisecond})
+	rl := limiter.NewRateLimiter(storage, 5, 60)
+	rl.SetDecisionTimeout(10 * time.Millisecond)
+	defer rl.Close()
+
+	rl.Allow(context.Background(), "192.168.1.1", "")
+	storage.SetConfig(strategy.FaultConfig{ErrorRate: 1})
+	rl.Allow(context.Background(), "192.168.1.1", "")
+	rl.Allow(context.Background(), "192.168.1.1", "")
+
+	rr := httptest.NewRecorder()
+	StorageFailuresHandler(rl).ServeHTTP(rr, httptest.NewRequest("GET", "/storage", nil))
+	var failures map[string]int64
+	if err := json.NewDecoder(rr.Body).Decode(&failures); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if failures["timeouts"] != 1 || failures["errors"] != 2 {
+		t.Fatalf("Expected 1 timeout and 2 errors, got %v", failures)
+	}
+}
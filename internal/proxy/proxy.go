@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Route forwards requests whose path starts with Prefix to Upstream. A prefix
+// without a trailing slash only matches whole path segments: /api matches
+// /api and /api/users but not /apiary.
+type Route struct {
+	Prefix   string
+	Upstream *url.URL
+	proxy    *httputil.ReverseProxy
+}
+
+// ParseRoutes parses a comma separated list of prefix=upstream pairs,
+// e.g. "/users/=http://users:8080,/legacy/=http://legacy:9000"
+func ParseRoutes(value string) ([]Route, error) {
+	var routes []Route
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		prefix, upstream, found := strings.Cut(pair, "=")
+		if !found || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid proxy route %q (expected /prefix=http://upstream)", pair)
+		}
+
+		target, err := url.Parse(upstream)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return nil, fmt.Errorf("invalid upstream URL in proxy route %q", pair)
+		}
+
+		routes = append(routes, Route{Prefix: prefix, Upstream: target})
+	}
+
+	if len(routes) == 0 {
+		return nil, errors.New("no proxy routes configured")
+	}
+	return routes, nil
+}
+
+// matches reports whether path is under prefix on a path segment boundary
+func matches(path string, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// Handler forwards requests to the upstream of the longest matching route prefix
+type Handler struct {
+	routes []Route
+}
+
+// NewHandler creates the reverse proxy. Upstreams must send response headers
+// within timeoutMs; bodies are streamed in both directions without buffering.
+// With stripPrefix the route prefix is removed from the forwarded path.
+func NewHandler(routes []Route, timeoutMs int, stripPrefix bool) *Handler {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Duration(timeoutMs) * time.Millisecond
+
+	sorted := make([]Route, len(routes))
+	copy(sorted, routes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+
+	for i := range sorted {
+		route := sorted[i]
+		sorted[i].proxy = &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				if stripPrefix {
+					r.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.In.URL.Path, route.Prefix), "/")
+					r.Out.URL.RawPath = ""
+				}
+				r.SetURL(route.Upstream)
+
+				// Keep the chain of proxies in front of us and append the client address
+				r.Out.Header["X-Forwarded-For"] = r.In.Header["X-Forwarded-For"]
+				r.SetXForwarded()
+			},
+			Transport:     transport,
+			FlushInterval: -1,
+			ErrorHandler:  errorHandler,
+		}
+	}
+
+	return &Handler{routes: sorted}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, route := range h.routes {
+		if matches(r.URL.Path, route.Prefix) {
+			route.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+// ParseTrustedProxies parses a comma separated list of IPs and CIDR ranges,
+// e.g. "10.0.0.0/8,192.168.1.10"
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var trusted []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q (expected an IP or CIDR)", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q (expected an IP or CIDR)", entry)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+// TrustForwardedHeaders makes the client address in X-Forwarded-For and
+// X-Real-IP trustworthy before the rate limiter reads it. Requests from peers
+// outside trusted have both headers removed, so clients cannot pick the IP
+// they are limited by. For trusted peers the X-Forwarded-For chain is cut to
+// start at the last address not in trusted, the client as seen by the
+// outermost trusted proxy.
+func TrustForwardedHeaders(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrusted(trusted, remoteIP(r)) {
+				r.Header.Del("X-Forwarded-For")
+				r.Header.Del("X-Real-IP")
+			} else if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+				chain := strings.Split(strings.Join(values, ","), ",")
+				start := 0
+				for i := len(chain) - 1; i >= 0; i-- {
+					if !isTrusted(trusted, net.ParseIP(strings.TrimSpace(chain[i]))) {
+						start = i
+						break
+					}
+				}
+				r.Header.Set("X-Forwarded-For", strings.TrimSpace(strings.Join(chain[start:], ",")))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP returns the address of the peer that sent the request
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// isTrusted reports whether ip belongs to one of the trusted ranges
+func isTrusted(trusted []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorHandler maps upstream failures to gateway errors
+func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	// The client went away, there is nobody to answer
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	log.Printf("proxy: upstream error for %s %s: %v", r.Method, r.URL.Path, err)
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		http.Error(w, "Gateway timeout", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, "Bad gateway", http.StatusBadGateway)
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseRoutes tests parsing of the route configuration
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes("/users/=http://users:8080, /legacy/=https://legacy.internal/base")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(routes) != 2 || routes[1].Upstream.Host != "legacy.internal" {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+
+	for _, invalid := range []string{"", "users=http://users", "/users/=ftp://users", "/users/"} {
+		if _, err := ParseRoutes(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestHandlerForwardsToLongestPrefix tests routing, path handling and forwarded headers
+func TestHandlerForwardsToLongestPrefix(t *testing.T) {
+	var gotPath, gotForwardedFor, gotForwardedHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotForwardedFor = r.Header.Get("X-Forwarded-For")
+		gotForwardedHost = r.Header.Get("X-Forwarded-Host")
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "reports")
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should go to the longest matching prefix")
+	}))
+	defer other.Close()
+
+	routes, _ := ParseRoutes("/api/=" + other.URL + ",/api/reports/=" + upstream.URL)
+	handler := NewHandler(routes, 1000, true)
+
+	req := httptest.NewRequest("POST", "/api/reports/monthly", strings.NewReader("payload"))
+	req.Host = "gateway.example.com"
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "payload" {
+		t.Fatalf("Unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Upstream") != "reports" {
+		t.Fatal("Upstream response headers should be copied")
+	}
+	if gotPath != "/monthly" {
+		t.Fatalf("Expected stripped path /monthly, got %s", gotPath)
+	}
+	if gotForwardedFor != "203.0.113.7, 10.0.0.2" {
+		t.Fatalf("Unexpected X-Forwarded-For %q", gotForwardedFor)
+	}
+	if gotForwardedHost != "gateway.example.com" {
+		t.Fatalf("Unexpected X-Forwarded-Host %q", gotForwardedHost)
+	}
+}
+
+// TestHandlerUnknownRoute tests that unmatched paths return 404
+func TestHandlerUnknownRoute(t *testing.T) {
+	routes, _ := ParseRoutes("/api/=http://127.0.0.1:1")
+	handler := NewHandler(routes, 1000, false)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// TestHandlerUpstreamTimeout tests that slow upstreams return 504
+func TestHandlerUpstreamTimeout(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	routes, _ := ParseRoutes("/=" + upstream.URL)
+	handler := NewHandler(routes, 50, false)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status 504, got %d", w.Code)
+	}
+}
+
+// TestHandlerMatchesWholeSegments tests that a prefix does not match a longer path segment
+func TestHandlerMatchesWholeSegments(t *testing.T) {
+	var gotPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	defer upstream.Close()
+
+	routes, _ := ParseRoutes("/api=" + upstream.URL)
+	handler := NewHandler(routes, 1000, false)
+
+	for _, path := range []string{"/api", "/api/users"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || gotPath != path {
+			t.Fatalf("Expected %s to be forwarded, got %d for %q", path, w.Code, gotPath)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/apiary", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for /apiary, got %d", w.Code)
+	}
+}
+
+// TestTrustForwardedHeaders tests that forwarded client addresses are only kept from trusted proxies
+func TestTrustForwardedHeaders(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Fatal("Expected error for an invalid CIDR")
+	}
+
+	var gotForwardedFor, gotRealIP string
+	handler := TrustForwardedHeaders(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotForwardedFor = r.Header.Get("X-Forwarded-For")
+		gotRealIP = r.Header.Get("X-Real-IP")
+	}))
+
+	tests := []struct {
+		remote, forwardedFor, want string
+	}{
+		{"203.0.113.7:1234", "198.51.100.1", ""},
+		{"192.168.1.10:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.2:1234", "1.2.3.4, 198.51.100.1, 10.0.0.3", "198.51.100.1, 10.0.0.3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		req.Header.Set("X-Real-IP", "198.51.100.9")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if gotForwardedFor != tt.want {
+			t.Fatalf("From %s: expected X-Forwarded-For %q, got %q", tt.remote, tt.want, gotForwardedFor)
+		}
+		if tt.want == "" && gotRealIP != "" {
+			t.Fatalf("From %s: X-Real-IP should be removed", tt.remote)
+		}
+	}
+}
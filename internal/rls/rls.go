@@ -0,0 +1,194 @@
+package rls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// Rule maps Envoy descriptors of a domain to a policy. A descriptor matches
+// when its entry keys are exactly Keys, in order; the entry values become
+// part of the counter key.
+type Rule struct {
+	Domain string
+	Keys   []string
+	Policy string
+}
+
+// ParseRules parses a comma separated list of domain:key1.key2=policy entries,
+// e.g. "edge:remote_address=per_ip,edge:generic_key.path=search"
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		match, policy, found := strings.Cut(entry, "=")
+		domain, keys, hasDomain := strings.Cut(match, ":")
+		if !found || !hasDomain || domain == "" || keys == "" || policy == "" {
+			return nil, fmt.Errorf("invalid RLS rule %q (expected domain:key1.key2=policy)", entry)
+		}
+
+		rules = append(rules, Rule{Domain: domain, Keys: strings.Split(keys, "."), Policy: policy})
+	}
+	return rules, nil
+}
+
+// Server implements the Envoy RateLimitService on top of RateLimiter.Check
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	rl    *limiter.RateLimiter
+	rules []Rule
+}
+
+// NewServer creates the RLS server, every rule must reference a configured policy
+func NewServer(rl *limiter.RateLimiter, rules []Rule) (*Server, error) {
+	for _, rule := range rules {
+		if _, exists := rl.Policy(rule.Policy); !exists {
+			return nil, fmt.Errorf("%w: %s", limiter.ErrUnknownPolicy, rule.Policy)
+		}
+	}
+	return &Server{rl: rl, rules: rules}, nil
+}
+
+// Register adds the service to a gRPC server
+func (s *Server) Register(server *grpc.Server) {
+	rlsv3.RegisterRateLimitServiceServer(server, s)
+}
+
+// ShouldRateLimit evaluates every descriptor and returns OVER_LIMIT when any of them is
+func (s *Server) ShouldRateLimit(ctx context.Context, request *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	if request.GetDomain() == "" {
+		return nil, status.Error(codes.InvalidArgument, "domain must not be empty")
+	}
+
+	// Envoy sends 0 when the hits addend is not set
+	hits := int(request.GetHitsAddend())
+	if hits == 0 {
+		hits = 1
+	}
+
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+		Statuses:    make([]*rlsv3.RateLimitResponse_DescriptorStatus, len(request.GetDescriptors())),
+	}
+
+	// Headers describe the descriptor closest to its limit
+	var tightest *rlsv3.RateLimitResponse_DescriptorStatus
+
+	for i, descriptor := range request.GetDescriptors() {
+		rule, matched := s.match(request.GetDomain(), descriptor)
+		if !matched {
+			// Descriptors without a rule are not limited
+			response.Statuses[i] = &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+			continue
+		}
+
+		decision, err := s.rl.Check(ctx, rule.Policy, keyParts(request.GetDomain(), descriptor), hits)
+		if errors.Is(err, limiter.ErrStorageUnavailable) {
+			return nil, status.Error(codes.Unavailable, "storage unavailable")
+		}
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		policy, _ := s.rl.Policy(rule.Policy)
+		descriptorStatus := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code: rlsv3.RateLimitResponse_OK,
+			CurrentLimit: &rlsv3.RateLimitResponse_RateLimit{
+				Name:            policy.Name,
+				RequestsPerUnit: uint32(policy.Limit),
+				Unit:            unit(policy.BlockDuration),
+			},
+			LimitRemaining:     uint32(decision.Remaining),
+			DurationUntilReset: durationpb.New(decision.Reset),
+		}
+		if !decision.Allowed {
+			descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		response.Statuses[i] = descriptorStatus
+
+		if tightest == nil || descriptorStatus.LimitRemaining < tightest.LimitRemaining {
+			tightest = descriptorStatus
+		}
+	}
+
+	if tightest != nil {
+		response.ResponseHeadersToAdd = headers(tightest)
+	}
+	return response, nil
+}
+
+// match returns the first rule of the domain whose keys equal the descriptor entry keys
+func (s *Server) match(domain string, descriptor *ratelimitv3.RateLimitDescriptor) (Rule, bool) {
+	entries := descriptor.GetEntries()
+	for _, rule := range s.rules {
+		if rule.Domain != domain || len(rule.Keys) != len(entries) {
+			continue
+		}
+
+		matched := true
+		for i, key := range rule.Keys {
+			if entries[i].GetKey() != key {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// keyParts builds the counter key from the domain and the descriptor entries
+func keyParts(domain string, descriptor *ratelimitv3.RateLimitDescriptor) []string {
+	parts := []string{domain}
+	for _, entry := range descriptor.GetEntries() {
+		parts = append(parts, entry.GetKey()+"="+entry.GetValue())
+	}
+	return parts
+}
+
+// unit reports the Envoy unit matching a window, UNKNOWN for windows without one
+func unit(seconds int) rlsv3.RateLimitResponse_RateLimit_Unit {
+	switch seconds {
+	case 1:
+		return rlsv3.RateLimitResponse_RateLimit_SECOND
+	case 60:
+		return rlsv3.RateLimitResponse_RateLimit_MINUTE
+	case 3600:
+		return rlsv3.RateLimitResponse_RateLimit_HOUR
+	case 86400:
+		return rlsv3.RateLimitResponse_RateLimit_DAY
+	default:
+		return rlsv3.RateLimitResponse_RateLimit_UNKNOWN
+	}
+}
+
+// headers returns the X-RateLimit-* headers Envoy adds to the client response
+func headers(descriptorStatus *rlsv3.RateLimitResponse_DescriptorStatus) []*core.HeaderValue {
+	reset := int(math.Ceil(descriptorStatus.GetDurationUntilReset().AsDuration().Seconds()))
+	return []*core.HeaderValue{
+		{Key: "X-RateLimit-Limit", Value: strconv.Itoa(int(descriptorStatus.GetCurrentLimit().GetRequestsPerUnit()))},
+		{Key: "X-RateLimit-Remaining", Value: strconv.Itoa(int(descriptorStatus.GetLimitRemaining()))},
+		{Key: "X-RateLimit-Reset", Value: strconv.Itoa(reset)},
+	}
+}
@@ -0,0 +1,204 @@
+package rls
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// newTestClient starts the RLS server on an in-memory listener and returns a client for it
+func newTestClient(t *testing.T, rl *limiter.RateLimiter, rules string) rlsv3.RateLimitServiceClient {
+	parsed, err := ParseRules(rules)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server, err := NewServer(rl, parsed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	server.Register(grpcServer)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return rlsv3.NewRateLimitServiceClient(conn)
+}
+
+// failingStorage simulates an unavailable storage
+type failingStorage struct{}
+
+var errStorageDown = errors.New("connection refused")
+
+func (failingStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	return 0, errStorageDown
+}
+func (failingStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	return errStorageDown
+}
+func (failingStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	return 0, errStorageDown
+}
+func (failingStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return false, errStorageDown
+}
+func (failingStorage) Delete(ctx context.Context, key string) error { return errStorageDown }
+func (failingStorage) Close() error                                 { return nil }
+
+func descriptor(pairs ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: pairs[i], Value: pairs[i+1]})
+	}
+	return d
+}
+
+// TestParseRules tests parsing of the descriptor rules
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("edge:remote_address=per_ip, edge:generic_key.path=search")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rules) != 2 || rules[1].Domain != "edge" || len(rules[1].Keys) != 2 || rules[1].Policy != "search" {
+		t.Fatalf("Unexpected rules %+v", rules)
+	}
+
+	for _, invalid := range []string{"edge", "edge=per_ip", "remote_address=per_ip", "edge:remote_address="} {
+		if _, err := ParseRules(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
+
+// TestNewServerRejectsUnknownPolicy tests that rules are validated against the limiter policies
+func TestNewServerRejectsUnknownPolicy(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rules, _ := ParseRules("edge:remote_address=missing")
+
+	if _, err := NewServer(rl, rules); err == nil {
+		t.Fatal("Expected error for unknown policy")
+	}
+}
+
+// TestShouldRateLimit tests per-descriptor statuses, the overall code and headers
+func TestShouldRateLimit(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("per_ip", 2, 60)
+	client := newTestClient(t, rl, "edge:remote_address=per_ip")
+
+	request := &rlsv3.RateLimitRequest{
+		Domain: "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{
+			descriptor("remote_address", "10.0.0.1"),
+			descriptor("path", "/unlimited"),
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		response, err := client.ShouldRateLimit(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.OverallCode != rlsv3.RateLimitResponse_OK {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+
+	response, err := client.ShouldRateLimit(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatal("Third request should be over limit")
+	}
+
+	limited := response.Statuses[0]
+	if limited.Code != rlsv3.RateLimitResponse_OVER_LIMIT || limited.CurrentLimit.RequestsPerUnit != 2 ||
+		limited.CurrentLimit.Unit != rlsv3.RateLimitResponse_RateLimit_MINUTE || limited.LimitRemaining != 0 {
+		t.Fatalf("Unexpected status %+v", limited)
+	}
+	if response.Statuses[1].Code != rlsv3.RateLimitResponse_OK || response.Statuses[1].CurrentLimit != nil {
+		t.Fatalf("Descriptor without rule should not be limited, got %+v", response.Statuses[1])
+	}
+
+	headers := map[string]string{}
+	for _, header := range response.ResponseHeadersToAdd {
+		headers[header.Key] = header.Value
+	}
+	if headers["X-RateLimit-Limit"] != "2" || headers["X-RateLimit-Remaining"] != "0" || headers["X-RateLimit-Reset"] != "60" {
+		t.Fatalf("Unexpected headers %v", headers)
+	}
+
+	// Other values of the same descriptor have their own counter
+	request.Descriptors = []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.2")}
+	response, _ = client.ShouldRateLimit(context.Background(), request)
+	if response.OverallCode != rlsv3.RateLimitResponse_OK {
+		t.Fatal("Other address should be allowed")
+	}
+}
+
+// TestShouldRateLimitHitsAddend tests that hits_addend consumes several units
+func TestShouldRateLimitHitsAddend(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("bytes", 10, 60)
+	client := newTestClient(t, rl, "edge:tenant=bytes")
+
+	request := &rlsv3.RateLimitRequest{
+		Domain:      "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("tenant", "acme")},
+		HitsAddend:  7,
+	}
+
+	response, _ := client.ShouldRateLimit(context.Background(), request)
+	if response.OverallCode != rlsv3.RateLimitResponse_OK || response.Statuses[0].LimitRemaining != 3 {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+
+	response, _ = client.ShouldRateLimit(context.Background(), request)
+	if response.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatal("Hits above the remaining units should be over limit")
+	}
+}
+
+// TestShouldRateLimitStorageUnavailable tests that storage failures map to UNAVAILABLE
+func TestShouldRateLimitStorageUnavailable(t *testing.T) {
+	rl := limiter.NewRateLimiter(failingStorage{}, 5, 300)
+	client := newTestClient(t, rl, "edge:remote_address=default")
+
+	_, err := client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+		Domain:      "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1")},
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Expected UNAVAILABLE, got %v", err)
+	}
+
+	_, err = client.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Expected INVALID_ARGUMENT for empty domain, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package simulator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log formats accepted by ParseLog
+const (
+	FormatAuto  = "auto"
+	FormatJSONL = "jsonl"
+	FormatCLF   = "clf"
+)
+
+// Entry is a request read from an access log
+type Entry struct {
+	Time   time.Time
+	IP     string
+	Token  string
+	Method string
+	Path   string
+}
+
+// clfLine matches the common and combined log formats, e.g.
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200 2326
+var clfLine = regexp.MustCompile(`^(\S+) \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)[^"]*" \d{3} `)
+
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// JSON field names accepted for each entry field, the first one present wins
+var (
+	jsonTimeFields   = []string{"time", "timestamp", "ts"}
+	jsonIPFields     = []string{"ip", "client_ip", "remote_addr"}
+	jsonTokenFields  = []string{"token", "api_key"}
+	jsonMethodFields = []string{"method"}
+	jsonPathFields   = []string{"path", "uri", "url"}
+)
+
+// ParseLog reads an access log in JSON Lines, common/combined log format or,
+// with FormatAuto, a mix of both detected per line. Lines that cannot be
+// parsed or that have neither an IP nor a token are skipped and counted.
+// JSON lines without a time take the time of the previous entry.
+func ParseLog(r io.Reader, format string) ([]Entry, int, error) {
+	if format != FormatAuto && format != FormatJSONL && format != FormatCLF {
+		return nil, 0, fmt.Errorf("invalid log format %q (expected auto, jsonl or clf)", format)
+	}
+
+	var entries []Entry
+	var skipped int
+	var last time.Time
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry Entry
+		var err error
+		if format == FormatJSONL || (format == FormatAuto && strings.HasPrefix(line, "{")) {
+			entry, err = parseJSONLine(line, last)
+		} else {
+			entry, err = parseCLFLine(line)
+		}
+		if err != nil || (entry.IP == "" && entry.Token == "") {
+			skipped++
+			continue
+		}
+
+		last = entry.Time
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, skipped, err
+	}
+	return entries, skipped, nil
+}
+
+func parseCLFLine(line string) (Entry, error) {
+	match := clfLine.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, fmt.Errorf("not a common log format line")
+	}
+	at, err := time.Parse(clfTime, match[2])
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Time: at, IP: match[1], Method: match[3], Path: requestPath(match[4])}, nil
+}
+
+func parseJSONLine(line string, last time.Time) (Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Entry{}, err
+	}
+
+	entry := Entry{
+		Time:   last,
+		IP:     stringField(fields, jsonIPFields),
+		Token:  stringField(fields, jsonTokenFields),
+		Method: strings.ToUpper(stringField(fields, jsonMethodFields)),
+		Path:   requestPath(stringField(fields, jsonPathFields)),
+	}
+	if host, _, err := net.SplitHostPort(entry.IP); err == nil {
+		entry.IP = host
+	}
+
+	for _, name := range jsonTimeFields {
+		value, exists := fields[name]
+		if !exists {
+			continue
+		}
+		at, err := parseJSONTime(value)
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		entry.Time = at
+		break
+	}
+	return entry, nil
+}
+
+// parseJSONTime accepts RFC 3339 strings and Unix timestamps in seconds
+func parseJSONTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(seconds), nil
+		}
+		return time.Parse(time.RFC3339Nano, v)
+	case float64:
+		return unixTime(v), nil
+	default:
+		return time.Time{}, fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+func unixTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+}
+
+func stringField(fields map[string]interface{}, names []string) string {
+	for _, name := range names {
+		if value, ok := fields[name].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// requestPath drops the query string, and the scheme and host of absolute URLs
+func requestPath(target string) string {
+	if strings.Contains(target, "://") {
+		if parsed, err := url.Parse(target); err == nil {
+			return parsed.Path
+		}
+	}
+	path, _, _ := strings.Cut(target, "?")
+	return path
+}
@@ -0,0 +1,128 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const reportTime = "2006-01-02 15:04:05"
+
+// WriteReport writes comparison tables of the candidates: a summary, the
+// denials per route and the top most denied keys across candidates
+func WriteReport(w io.Writer, reports []Report, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "POLICY\tLIMIT\tWINDOW\tREQUESTS\tDENIED\tDENIED %\tKEYS DENIED\tFIRST DENY")
+	for _, report := range reports {
+		keysDenied := 0
+		for _, key := range report.Keys {
+			if key.Denied > 0 {
+				keysDenied++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%ds\t%d\t%d\t%.2f\t%d\t%s\n",
+			report.Policy.Name, report.Policy.Limit, report.Policy.BlockDuration,
+			report.Requests, report.Denied, percent(report.Denied, report.Requests),
+			keysDenied, formatTime(report.FirstDeny))
+	}
+
+	fmt.Fprintf(tw, "\nROUTE\tREQUESTS\t%s\n", policyColumns(reports, "DENIED"))
+	routes := make(map[string]int)
+	routeIndex := make([]map[string]RouteStats, len(reports))
+	for i, report := range reports {
+		routeIndex[i] = make(map[string]RouteStats, len(report.Routes))
+		for _, route := range report.Routes {
+			routes[route.Route] = route.Requests
+			routeIndex[i][route.Route] = route
+		}
+	}
+	for _, route := range sortedNames(routes) {
+		columns := make([]string, len(reports))
+		for i := range reports {
+			stats := routeIndex[i][route]
+			columns[i] = fmt.Sprintf("%d (%.2f%%)", stats.Denied, percent(stats.Denied, stats.Requests))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", route, routes[route], strings.Join(columns, "\t"))
+	}
+
+	fmt.Fprintf(tw, "\nKEY\tREQUESTS\t%s\n", policyColumns(reports, "DENIED / FIRST DENY"))
+	keyIndex := make([]map[string]KeyStats, len(reports))
+	for i, report := range reports {
+		keyIndex[i] = make(map[string]KeyStats, len(report.Keys))
+		for _, key := range report.Keys {
+			keyIndex[i][key.Key] = key
+		}
+	}
+	for _, key := range topKeys(reports, top) {
+		requests := 0
+		columns := make([]string, len(reports))
+		for i := range reports {
+			stats := keyIndex[i][key]
+			requests = max(requests, stats.Requests)
+			columns[i] = "0"
+			if stats.Denied > 0 {
+				columns[i] = fmt.Sprintf("%d / %s", stats.Denied, formatTime(stats.FirstDeny))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", key, requests, strings.Join(columns, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// topKeys returns up to top keys denied by any candidate, ordered by the
+// most denials under a single candidate
+func topKeys(reports []Report, top int) []string {
+	denied := make(map[string]int)
+	for _, report := range reports {
+		for _, key := range report.Keys {
+			if key.Denied > 0 {
+				denied[key.Key] = max(denied[key.Key], key.Denied)
+			}
+		}
+	}
+
+	keys := sortedNames(denied)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return denied[keys[i]] > denied[keys[j]]
+	})
+	if len(keys) > top {
+		keys = keys[:top]
+	}
+	return keys
+}
+
+func policyColumns(reports []Report, suffix string) string {
+	columns := make([]string, len(reports))
+	for i, report := range reports {
+		columns[i] = strings.ToUpper(report.Policy.Name) + " " + suffix
+	}
+	return strings.Join(columns, "\t")
+}
+
+func sortedNames(values map[string]int) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func percent(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(reportTime)
+}
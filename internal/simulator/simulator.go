@@ -0,0 +1,166 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// Config selects what a replay compares
+type Config struct {
+	// Candidates are the default limits compared, each replays the whole log.
+	// Name labels the candidate in the reports.
+	Candidates []limiter.Policy
+	// Tokens overrides the limits of specific tokens in every candidate, the
+	// policy name is the token
+	Tokens []limiter.Policy
+	// RouteDepth is the number of path segments that identify a route, e.g. 2
+	// groups /api/users/42 as /api/users. Zero keeps the full path.
+	RouteDepth int
+}
+
+// Report is the outcome of replaying a log under one candidate
+type Report struct {
+	Policy   limiter.Policy
+	Requests int
+	Denied   int
+	// FirstDeny is the log time of the first denied request, zero if none was denied
+	FirstDeny time.Time
+	// Keys are sorted by denied requests, most denied first
+	Keys []KeyStats
+	// Routes are sorted by route
+	Routes []RouteStats
+}
+
+// KeyStats are the outcomes for one client, "ip:<address>" or "token:<token>"
+type KeyStats struct {
+	Key       string
+	Requests  int
+	Denied    int
+	FirstDeny time.Time
+}
+
+// RouteStats are the outcomes for one route
+type RouteStats struct {
+	Route    string
+	Requests int
+	Denied   int
+}
+
+// Run replays the entries under each candidate with a fresh in-memory
+// storage. Time is virtual: it follows the log timestamps, so windows and
+// blocks expire as they would have in production without waiting. Entries
+// out of order are replayed at the latest time seen.
+func Run(ctx context.Context, entries []Entry, config Config) ([]Report, error) {
+	if len(config.Candidates) == 0 {
+		return nil, fmt.Errorf("at least one candidate policy is required")
+	}
+
+	reports := make([]Report, 0, len(config.Candidates))
+	for _, candidate := range config.Candidates {
+		report, err := replay(ctx, entries, candidate, config)
+		if err != nil {
+			return nil, fmt.Errorf("replaying %s: %w", candidate.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func replay(ctx context.Context, entries []Entry, candidate limiter.Policy, config Config) (Report, error) {
+	now := clock.NewFake(time.Time{})
+	storage := strategy.NewMemoryStorageWithClock(now)
+	rl := limiter.NewRateLimiter(storage, candidate.Limit, candidate.BlockDuration)
+	defer rl.Close()
+	for _, token := range config.Tokens {
+		rl.ConfigureToken(token.Name, token.Limit, token.BlockDuration)
+	}
+
+	report := Report{Policy: candidate}
+	keys := make(map[string]*KeyStats)
+	routes := make(map[string]*RouteStats)
+
+	for _, entry := range entries {
+		if entry.Time.After(now.Now()) {
+			now.Set(entry.Time)
+		}
+
+		allowed, err := rl.Allow(ctx, entry.IP, entry.Token)
+		if err != nil {
+			return Report{}, err
+		}
+
+		key := "ip:" + entry.IP
+		if entry.Token != "" {
+			key = "token:" + entry.Token
+		}
+		keyStats := keys[key]
+		if keyStats == nil {
+			keyStats = &KeyStats{Key: key}
+			keys[key] = keyStats
+		}
+		route := routeOf(entry.Path, config.RouteDepth)
+		routeStats := routes[route]
+		if routeStats == nil {
+			routeStats = &RouteStats{Route: route}
+			routes[route] = routeStats
+		}
+
+		report.Requests++
+		keyStats.Requests++
+		routeStats.Requests++
+		if allowed {
+			continue
+		}
+
+		report.Denied++
+		keyStats.Denied++
+		routeStats.Denied++
+		if report.FirstDeny.IsZero() {
+			report.FirstDeny = now.Now()
+		}
+		if keyStats.FirstDeny.IsZero() {
+			keyStats.FirstDeny = now.Now()
+		}
+	}
+
+	for _, stats := range keys {
+		report.Keys = append(report.Keys, *stats)
+	}
+	sort.Slice(report.Keys, func(i, j int) bool {
+		if report.Keys[i].Denied != report.Keys[j].Denied {
+			return report.Keys[i].Denied > report.Keys[j].Denied
+		}
+		return report.Keys[i].Key < report.Keys[j].Key
+	})
+
+	for _, stats := range routes {
+		report.Routes = append(report.Routes, *stats)
+	}
+	sort.Slice(report.Routes, func(i, j int) bool {
+		return report.Routes[i].Route < report.Routes[j].Route
+	})
+	return report, nil
+}
+
+// routeOf keeps the first depth segments of a path
+func routeOf(path string, depth int) string {
+	if path == "" {
+		return "-"
+	}
+	if depth <= 0 {
+		return path
+	}
+
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,96 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// TestParseLog tests JSON Lines and common/combined log format lines, mixed with auto detection
+func TestParseLog(t *testing.T) {
+	log := strings.Join([]string{
+		`{"time": "2026-10-19T12:00:00Z", "remote_addr": "10.0.0.1:5123", "method": "get", "path": "/api/users/1?page=2"}`,
+		`{"token": "acme", "url": "https://api.example.com/api/search"}`,
+		`{"ts": 1792411201.5, "ip": "10.0.0.2"}`,
+		`10.0.0.3 - frank [19/Oct/2026:09:00:02 -0300] "POST /api/export HTTP/1.1" 200 2326 "-" "curl/8.0"`,
+		`not a log line`,
+		`{"path": "/no/client"}`,
+	}, "\n")
+
+	entries, skipped, err := ParseLog(strings.NewReader(log), FormatAuto)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if skipped != 2 || len(entries) != 4 {
+		t.Fatalf("Expected 4 entries and 2 skipped lines, got %d and %d", len(entries), skipped)
+	}
+
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	if entries[0] != (Entry{Time: start, IP: "10.0.0.1", Method: "GET", Path: "/api/users/1"}) {
+		t.Fatalf("Unexpected JSON entry %+v", entries[0])
+	}
+	if entries[1].Token != "acme" || entries[1].Path != "/api/search" || !entries[1].Time.Equal(start) {
+		t.Fatalf("Lines without a time should take the previous one, got %+v", entries[1])
+	}
+	if !entries[2].Time.Equal(time.Unix(1792411201, 5e8)) {
+		t.Fatalf("Unexpected Unix time %v", entries[2].Time)
+	}
+	if entries[3].IP != "10.0.0.3" || entries[3].Method != "POST" || !entries[3].Time.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("Unexpected common log entry %+v", entries[3])
+	}
+
+	if _, _, err := ParseLog(strings.NewReader(log), "xml"); err == nil {
+		t.Fatal("Expected error for an unknown format")
+	}
+}
+
+// TestRunUsesLogTime tests that windows expire with the log timestamps, not the wall clock
+func TestRunUsesLogTime(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	var entries []Entry
+	// 3 requests per second for 3 seconds, then one after the 10 second block
+	for i := 0; i < 9; i++ {
+		entries = append(entries, Entry{Time: start.Add(time.Duration(i/3) * time.Second), IP: "10.0.0.1", Path: "/api/users/1"})
+	}
+	entries = append(entries, Entry{Time: start.Add(11 * time.Second), IP: "10.0.0.1", Path: "/api/users/2"})
+	entries = append(entries, Entry{Time: start.Add(11 * time.Second), Token: "acme", Path: "/health"})
+
+	reports, err := Run(context.Background(), entries, Config{
+		Candidates: []limiter.Policy{{Name: "strict", Limit: 2, BlockDuration: 10}, {Name: "relaxed", Limit: 3, BlockDuration: 1}},
+		Tokens:     []limiter.Policy{{Name: "acme", Limit: 0, BlockDuration: 60}},
+		RouteDepth: 2,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	strict := reports[0]
+	if strict.Requests != 11 || strict.Denied != 8 || !strict.FirstDeny.Equal(start) {
+		t.Fatalf("Unexpected strict report %+v", strict)
+	}
+	if strict.Keys[0] != (KeyStats{Key: "ip:10.0.0.1", Requests: 10, Denied: 7, FirstDeny: start}) {
+		t.Fatalf("The IP should be allowed again after the block, got %+v", strict.Keys[0])
+	}
+	if len(strict.Routes) != 2 || strict.Routes[0] != (RouteStats{Route: "/api/users", Requests: 10, Denied: 7}) {
+		t.Fatalf("Unexpected routes %+v", strict.Routes)
+	}
+
+	relaxed := reports[1]
+	if relaxed.Denied != 1 || relaxed.Keys[0].Key != "token:acme" {
+		t.Fatalf("Only the token over its own limit should be denied, got %+v", relaxed)
+	}
+
+	var out bytes.Buffer
+	if err := WriteReport(&out, reports, 5); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"STRICT DENIED", "/api/users", "7 / 2026-10-19 12:00:00"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Report should contain %q:\n%s", expected, out.String())
+		}
+	}
+}
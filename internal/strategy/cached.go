@@ -0,0 +1,471 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+)
+
+type blockedEntry struct {
+	counter int
+	until   time.Time
+}
+
+type batchEntry struct {
+	remote     int
+	pending    int
+	ttlSeconds int
+	syncedAt   time.Time
+	// expiresAt is when the window ends in the wrapped storage, zero if unknown
+	expiresAt time.Time
+}
+
+// flushTimeout bounds a background flush of stale batches
+const flushTimeout = 5 * time.Second
+
+// CachedStorage is a local cache in front of another StorageStrategy. Keys
+// reported as blocked are answered locally until their window expires, and
+// increments for allowed clients can optionally be batched: each instance may
+// then admit up to batchSize-1 extra requests per key, and local counters are
+// never older than maxStaleness. Batches are flushed when full, when their
+// key is used after maxStaleness, and in the background for idle keys. Local
+// counts never outlive the window they belong to.
+type CachedStorage struct {
+	storage      StorageStrategy
+	batchSize    int
+	maxStaleness time.Duration
+	maxKeys      int
+	clock        clock.Clock
+
+	mu       sync.Mutex
+	blocked  map[string]blockedEntry
+	batches  map[string]*batchEntry
+	fullSeen bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCachedStorage creates the cache. A batchSize of 0 or 1 disables batching;
+// batching also requires the wrapped storage to implement BatchIncrementer.
+func NewCachedStorage(storage StorageStrategy, batchSize int, maxStalenessMs int, maxKeys int) *CachedStorage {
+	return NewCachedStorageWithClock(storage, batchSize, maxStalenessMs, maxKeys, clock.Real)
+}
+
+// NewCachedStorageWithClock creates a cache that ages blocked keys and batches
+// according to the clock, e.g. the clock of a wrapped memory storage in tests.
+// The background flush still runs on the wall clock.
+func NewCachedStorageWithClock(storage StorageStrategy, batchSize int, maxStalenessMs int, maxKeys int, clock clock.Clock) *CachedStorage {
+	if _, ok := storage.(BatchIncrementer); !ok {
+		batchSize = 0
+	}
+
+	c := &CachedStorage{
+		storage:      storage,
+		batchSize:    batchSize,
+		maxStaleness: time.Duration(maxStalenessMs) * time.Millisecond,
+		maxKeys:      maxKeys,
+		clock:        clock,
+		blocked:      make(map[string]blockedEntry),
+		batches:      make(map[string]*batchEntry),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	if c.batching() && c.maxStaleness > 0 {
+		go c.flushLoop()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+// batching reports whether increments are batched
+func (c *CachedStorage) batching() bool {
+	return c.batchSize > 1
+}
+
+// RecordBlock caches the blocked key until its window expires in the wrapped storage
+func (c *CachedStorage) RecordBlock(ctx context.Context, key string, counter int) {
+	reader, ok := c.storage.(TTLReader)
+	if !ok {
+		return
+	}
+
+	// Denials answered from the cache are reported again, skip the round trip
+	if _, blocked := c.blockedCounter(key); blocked {
+		return
+	}
+
+	ttl, err := reader.TTL(ctx, key)
+	if err != nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.full(len(c.blocked)) {
+		c.sweep()
+		if c.full(len(c.blocked)) {
+			return
+		}
+	}
+	c.blocked[key] = blockedEntry{counter: counter, until: c.clock.Now().Add(ttl)}
+}
+
+// full reports whether a map of the given size reached the key limit
+func (c *CachedStorage) full(size int) bool {
+	return c.maxKeys > 0 && size >= c.maxKeys
+}
+
+// sweep drops expired blocked entries; the caller must hold the lock
+func (c *CachedStorage) sweep() {
+	now := c.clock.Now()
+	for key, entry := range c.blocked {
+		if !now.Before(entry.until) {
+			delete(c.blocked, key)
+		}
+	}
+}
+
+// blockedCounter returns the cached counter of a blocked key
+func (c *CachedStorage) blockedCounter(key string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.blocked[key]
+	if !exists {
+		return 0, false
+	}
+	if !c.clock.Now().Before(entry.until) {
+		delete(c.blocked, key)
+		return 0, false
+	}
+	return entry.counter, true
+}
+
+// fresh reports whether a batch can still answer locally: it was synced
+// within maxStaleness and its window did not end; the caller must hold the lock
+func (c *CachedStorage) fresh(entry *batchEntry) bool {
+	now := c.clock.Now()
+	if now.Sub(entry.syncedAt) >= c.maxStaleness {
+		return false
+	}
+	return entry.expiresAt.IsZero() || now.Before(entry.expiresAt)
+}
+
+// detach removes the batch of a key and returns it, so no other caller can
+// flush its pending increments again; the caller must hold the lock
+func (c *CachedStorage) detach(key string) batchEntry {
+	entry, exists := c.batches[key]
+	if !exists {
+		return batchEntry{}
+	}
+	delete(c.batches, key)
+	return *entry
+}
+
+// restore puts back increments that could not be flushed. The batch is
+// marked stale so the next use of the key flushes it.
+func (c *CachedStorage) restore(key string, pending int, ttlSeconds int) {
+	if pending == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, exists := c.batches[key]; exists {
+		entry.pending += pending
+		entry.ttlSeconds = max(entry.ttlSeconds, ttlSeconds)
+		entry.syncedAt = time.Time{}
+		return
+	}
+	c.batches[key] = &batchEntry{pending: pending, ttlSeconds: ttlSeconds}
+}
+
+// remember starts a new batch for the key from the stored value
+func (c *CachedStorage) remember(key string, remote int, ttlSeconds int, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.batches[key]; exists {
+		return
+	}
+	if c.full(len(c.batches)) {
+		if !c.fullSeen {
+			log.Printf("cached storage: %d keys are batched, increments for new keys go straight to the storage", len(c.batches))
+			c.fullSeen = true
+		}
+		return
+	}
+	c.fullSeen = false
+	c.batches[key] = &batchEntry{remote: remote, ttlSeconds: ttlSeconds, syncedAt: c.clock.Now(), expiresAt: expiresAt}
+}
+
+// flush writes n increments to the wrapped storage. applied reports whether
+// the increments reached the storage, even if setting the TTL then failed.
+func (c *CachedStorage) flush(ctx context.Context, key string, n int, ttlSeconds int) (val int, applied bool, err error) {
+	val, err = c.storage.(BatchIncrementer).IncrementCounterBy(ctx, key, n)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// The window expired meanwhile and the increment created a new key without TTL
+	if val == n && ttlSeconds > 0 {
+		if err := c.storage.SetExpiration(ctx, key, ttlSeconds); err != nil {
+			return 0, true, err
+		}
+	}
+	return val, true, nil
+}
+
+func (c *CachedStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	if !c.batching() {
+		return c.storage.IncrementCounter(ctx, key)
+	}
+
+	c.mu.Lock()
+	if entry, exists := c.batches[key]; exists && c.fresh(entry) && entry.ttlSeconds > 0 && entry.pending+1 < c.batchSize {
+		// Keep counting locally until the batch is full
+		entry.pending++
+		val := entry.remote + entry.pending
+		c.mu.Unlock()
+		return val, nil
+	}
+	batch := c.detach(key)
+	c.mu.Unlock()
+
+	// This increment goes with the pending ones, so the caller sees the real value
+	n := batch.pending + 1
+	val, applied, err := c.flush(ctx, key, n, batch.ttlSeconds)
+	if err != nil {
+		if !applied {
+			c.restore(key, batch.pending, batch.ttlSeconds)
+		}
+		return 0, err
+	}
+
+	ttlSeconds, expiresAt := batch.ttlSeconds, batch.expiresAt
+	switch {
+	case val == n && ttlSeconds > 0:
+		// The flush started the window again
+		expiresAt = c.clock.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	case val != 1 && expiresAt.IsZero():
+		// A window started elsewhere: read when it ends, and keep its TTL in
+		// case a flush has to recreate it
+		if ttl := c.remaining(ctx, key); ttl > 0 {
+			expiresAt = c.clock.Now().Add(ttl)
+			if ttlSeconds == 0 {
+				ttlSeconds = int((ttl + time.Second - 1) / time.Second)
+			}
+		}
+	}
+	c.remember(key, val, ttlSeconds, expiresAt)
+	return val, nil
+}
+
+// Consume answers blocked keys locally and forwards the rest to the wrapped
+// storage. Batched increments cannot be atomic, so it is unsupported while batching.
+func (c *CachedStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	consumer, ok := c.storage.(AtomicConsumer)
+	if !ok || c.batching() {
+		return 0, false, errors.ErrUnsupported
+	}
+	if counter, blocked := c.blockedCounter(key); blocked {
+		return counter, false, nil
+	}
+	return consumer.Consume(ctx, key, cost, limit, ttlSeconds)
+}
+
+// Leases change on every request and are never cached, they go straight to the wrapped storage
+
+// Unwrap returns the wrapped storage
+func (c *CachedStorage) Unwrap() StorageStrategy {
+	return c.storage
+}
+
+func (c *CachedStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error) {
+	leases, ok := c.storage.(LeaseStorage)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+	return leases.AcquireLease(ctx, key, leaseID, limit, lease)
+}
+
+func (c *CachedStorage) RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error {
+	leases, ok := c.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return leases.RenewLease(ctx, key, leaseID, lease)
+}
+
+func (c *CachedStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	leases, ok := c.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return leases.ReleaseLease(ctx, key, leaseID)
+}
+
+// Usage totals are aggregated by the caller and go straight to the wrapped storage
+
+func (c *CachedStorage) AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error {
+	usage, ok := c.storage.(UsageStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return usage.AddUsage(ctx, hour, totals, retention)
+}
+
+func (c *CachedStorage) Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error) {
+	usage, ok := c.storage.(UsageStorage)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return usage.Usage(ctx, from, to)
+}
+
+// remaining returns the remaining TTL of a key, or zero if unknown
+func (c *CachedStorage) remaining(ctx context.Context, key string) time.Duration {
+	reader, ok := c.storage.(TTLReader)
+	if !ok {
+		return 0
+	}
+	ttl, err := reader.TTL(ctx, key)
+	if err != nil || ttl <= 0 {
+		return 0
+	}
+	return ttl
+}
+
+func (c *CachedStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	if c.batching() {
+		c.mu.Lock()
+		if entry, exists := c.batches[key]; exists {
+			entry.ttlSeconds = ttlSeconds
+			entry.expiresAt = c.clock.Now().Add(time.Duration(ttlSeconds) * time.Second)
+		}
+		c.mu.Unlock()
+	}
+	return c.storage.SetExpiration(ctx, key, ttlSeconds)
+}
+
+func (c *CachedStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	if counter, blocked := c.blockedCounter(key); blocked {
+		return counter, nil
+	}
+
+	if c.batching() {
+		c.mu.Lock()
+		if entry, exists := c.batches[key]; exists && c.fresh(entry) {
+			val := entry.remote + entry.pending
+			c.mu.Unlock()
+			return val, nil
+		}
+		batch := c.detach(key)
+		c.mu.Unlock()
+
+		if batch.pending > 0 {
+			val, applied, err := c.flush(ctx, key, batch.pending, batch.ttlSeconds)
+			if err != nil && !applied {
+				c.restore(key, batch.pending, batch.ttlSeconds)
+			}
+			return val, err
+		}
+	}
+
+	return c.storage.GetCounter(ctx, key)
+}
+
+func (c *CachedStorage) Exists(ctx context.Context, key string) (bool, error) {
+	return c.storage.Exists(ctx, key)
+}
+
+func (c *CachedStorage) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	delete(c.blocked, key)
+	delete(c.batches, key)
+	c.mu.Unlock()
+
+	return c.storage.Delete(ctx, key)
+}
+
+// Flush writes every pending batched increment to the wrapped storage.
+// Increments that could not be written are kept for a later flush.
+func (c *CachedStorage) Flush(ctx context.Context) error {
+	return c.flushBatches(ctx, false)
+}
+
+// flushBatches writes the pending increments of every batch, or only of
+// stale ones, and drops the flushed batches
+func (c *CachedStorage) flushBatches(ctx context.Context, staleOnly bool) error {
+	c.mu.Lock()
+	batches := make(map[string]batchEntry)
+	for key, entry := range c.batches {
+		if staleOnly && c.fresh(entry) {
+			continue
+		}
+		if batch := c.detach(key); batch.pending > 0 {
+			batches[key] = batch
+		}
+	}
+	c.mu.Unlock()
+
+	var firstErr error
+	for key, batch := range batches {
+		_, applied, err := c.flush(ctx, key, batch.pending, batch.ttlSeconds)
+		if err == nil {
+			continue
+		}
+		if !applied {
+			c.restore(key, batch.pending, batch.ttlSeconds)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// flushLoop flushes stale batches, so increments for keys that are not used
+// again reach the storage within about maxStaleness
+func (c *CachedStorage) flushLoop() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.maxStaleness)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			if err := c.flushBatches(ctx, true); err != nil {
+				log.Printf("cached storage: failed to flush batched increments: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (c *CachedStorage) Ping(ctx context.Context) error {
+	return Ping(ctx, c.storage)
+}
+
+// Close stops the background flush, writes the pending increments and closes
+// the wrapped storage
+func (c *CachedStorage) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	<-c.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return errors.Join(c.Flush(ctx), c.storage.Close())
+}
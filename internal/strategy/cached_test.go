@@ -0,0 +1,236 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStorage counts the calls that reach the wrapped memory storage
+type countingStorage struct {
+	*MemoryStorage
+	calls int
+}
+
+func (c *countingStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	c.calls++
+	return c.MemoryStorage.IncrementCounter(ctx, key)
+}
+
+func (c *countingStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	c.calls++
+	return c.MemoryStorage.IncrementCounterBy(ctx, key, n)
+}
+
+func (c *countingStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	c.calls++
+	return c.MemoryStorage.GetCounter(ctx, key)
+}
+
+// failingStorage fails batched increments while down is set
+type failingStorage struct {
+	*MemoryStorage
+	down atomic.Bool
+}
+
+var errStorageDown = errors.New("storage down")
+
+func (f *failingStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	if f.down.Load() {
+		return 0, errStorageDown
+	}
+	return f.MemoryStorage.IncrementCounterBy(ctx, key, n)
+}
+
+// TestCachedStorageAnswersBlockedKeysLocally tests that blocked keys skip the wrapped storage
+func TestCachedStorageAnswersBlockedKeysLocally(t *testing.T) {
+	inner := &countingStorage{MemoryStorage: NewMemoryStorage()}
+	cache := NewCachedStorage(inner, 0, 100, 1000)
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+	cache.RecordBlock(ctx, "key", 1)
+
+	calls := inner.calls
+	for i := 0; i < 10; i++ {
+		if val, _ := cache.GetCounter(ctx, "key"); val != 1 {
+			t.Fatalf("Expected cached counter 1, got %d", val)
+		}
+	}
+	if inner.calls != calls {
+		t.Fatalf("Blocked key should not reach the storage, got %d extra calls", inner.calls-calls)
+	}
+
+	// Deleting the key clears the local block
+	cache.Delete(ctx, "key")
+	if val, _ := cache.GetCounter(ctx, "key"); val != 0 {
+		t.Fatalf("Expected counter 0 after delete, got %d", val)
+	}
+}
+
+// TestCachedStorageBatchesIncrements tests that increments are flushed in batches
+func TestCachedStorageBatchesIncrements(t *testing.T) {
+	inner := &countingStorage{MemoryStorage: NewMemoryStorage()}
+	cache := NewCachedStorage(inner, 5, int(time.Minute/time.Millisecond), 1000)
+	ctx := context.Background()
+
+	if val, _ := cache.IncrementCounter(ctx, "key"); val != 1 {
+		t.Fatalf("Expected first increment to return 1, got %d", val)
+	}
+	cache.SetExpiration(ctx, "key", 60)
+
+	for i := 2; i <= 6; i++ {
+		if val, _ := cache.IncrementCounter(ctx, "key"); val != i {
+			t.Fatalf("Expected local counter %d, got %d", i, val)
+		}
+	}
+
+	// One synchronous increment plus one flush of a full batch
+	if inner.calls != 2 {
+		t.Fatalf("Expected 2 calls to the storage, got %d", inner.calls)
+	}
+	if val, _ := inner.MemoryStorage.GetCounter(ctx, "key"); val != 6 {
+		t.Fatalf("Expected stored counter 6, got %d", val)
+	}
+
+	cache.IncrementCounter(ctx, "key")
+	cache.Flush(ctx)
+	if val, _ := inner.MemoryStorage.GetCounter(ctx, "key"); val != 7 {
+		t.Fatalf("Expected stored counter 7 after flush, got %d", val)
+	}
+}
+
+// TestCachedStorageFlushKeepsTTL tests that a flush recreating an expired window sets its TTL again
+func TestCachedStorageFlushKeepsTTL(t *testing.T) {
+	inner := NewMemoryStorage()
+	cache := NewCachedStorage(inner, 10, int(time.Minute/time.Millisecond), 1000)
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+	cache.IncrementCounter(ctx, "key")
+
+	// Simulate the window expiring in the wrapped storage before the flush
+	inner.Delete(ctx, "key")
+	cache.Flush(ctx)
+
+	ttl, _ := inner.TTL(ctx, "key")
+	if ttl <= 0 {
+		t.Fatal("Recreated key must have a TTL")
+	}
+}
+
+// TestCachedStorageConcurrentIncrements tests that concurrent increments on one
+// key are all written to the storage; run it with -race
+func TestCachedStorageConcurrentIncrements(t *testing.T) {
+	inner := NewMemoryStorage()
+	cache := NewCachedStorage(inner, 7, 1, 1000)
+	defer cache.Close()
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+
+	const workers, perWorker = 16, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if _, err := cache.IncrementCounter(ctx, "key"); err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := cache.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error flushing the cache: %v", err)
+	}
+	if val, _ := inner.GetCounter(ctx, "key"); val != 1+workers*perWorker {
+		t.Fatalf("Expected stored counter %d, got %d", 1+workers*perWorker, val)
+	}
+}
+
+// TestCachedStorageKeepsIncrementsOnFailedFlush tests that increments that
+// could not be flushed are written by a later flush
+func TestCachedStorageKeepsIncrementsOnFailedFlush(t *testing.T) {
+	inner := &failingStorage{MemoryStorage: NewMemoryStorage()}
+	cache := NewCachedStorage(inner, 10, int(time.Minute/time.Millisecond), 1000)
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+	for i := 0; i < 3; i++ {
+		cache.IncrementCounter(ctx, "key")
+	}
+
+	inner.down.Store(true)
+	if err := cache.Flush(ctx); !errors.Is(err, errStorageDown) {
+		t.Fatalf("Expected the storage error, got %v", err)
+	}
+
+	inner.down.Store(false)
+	if err := cache.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if val, _ := inner.GetCounter(ctx, "key"); val != 4 {
+		t.Fatalf("Expected stored counter 4, got %d", val)
+	}
+}
+
+// TestCachedStorageFlushesIdleKeys tests that the background flush writes
+// batches of keys that are not used again
+func TestCachedStorageFlushesIdleKeys(t *testing.T) {
+	inner := NewMemoryStorage()
+	cache := NewCachedStorage(inner, 10, 20, 1)
+	defer cache.Close()
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+	cache.IncrementCounter(ctx, "key")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if val, _ := inner.GetCounter(ctx, "key"); val == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Pending increment was not flushed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The flushed batch is dropped, so a new key can be batched again
+	cache.IncrementCounter(ctx, "other")
+	cache.mu.Lock()
+	_, batched := cache.batches["other"]
+	cache.mu.Unlock()
+	if !batched {
+		t.Fatal("Expected the new key to be batched")
+	}
+}
+
+// TestCachedStorageCloseReturnsFlushError tests that Close reports increments it could not write
+func TestCachedStorageCloseReturnsFlushError(t *testing.T) {
+	inner := &failingStorage{MemoryStorage: NewMemoryStorage()}
+	cache := NewCachedStorage(inner, 10, int(time.Minute/time.Millisecond), 1000)
+	ctx := context.Background()
+
+	cache.IncrementCounter(ctx, "key")
+	cache.SetExpiration(ctx, "key", 60)
+	cache.IncrementCounter(ctx, "key")
+
+	inner.down.Store(true)
+	if err := cache.Close(); !errors.Is(err, errStorageDown) {
+		t.Fatalf("Expected the flush error from Close, got %v", err)
+	}
+}
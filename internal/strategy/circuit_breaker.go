@@ -0,0 +1,292 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned while the circuit breaker is rejecting calls to the storage
+var ErrCircuitOpen = errors.New("storage circuit breaker is open")
+
+// CircuitState represents the state of a circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every call through to the storage
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every call without touching the storage
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe call through to check for recovery
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerStorage wraps a StorageStrategy and stops calling it after
+// consecutive failures, probing again once the open timeout has elapsed
+type CircuitBreakerStorage struct {
+	storage     StorageStrategy
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerStorage creates a circuit breaker that opens after threshold
+// consecutive failures and stays open for openTimeoutSeconds before probing
+func NewCircuitBreakerStorage(storage StorageStrategy, threshold int, openTimeoutSeconds int) *CircuitBreakerStorage {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return &CircuitBreakerStorage{
+		storage:     storage,
+		threshold:   threshold,
+		openTimeout: time.Duration(openTimeoutSeconds) * time.Second,
+	}
+}
+
+// State returns the current state of the circuit breaker
+func (cb *CircuitBreakerStorage) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.openTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// before decides whether a call may reach the storage
+func (cb *CircuitBreakerStorage) before() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			return ErrCircuitOpen
+		}
+		cb.state = CircuitHalfOpen
+		cb.probing = true
+		return nil
+	case CircuitHalfOpen:
+		// Only one probe at a time while recovering
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// after records the outcome of a call that reached the storage
+func (cb *CircuitBreakerStorage) after(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.probing = false
+
+	// A cancelled caller says nothing about the storage health
+	if err != nil && errors.Is(err, context.Canceled) {
+		if cb.state == CircuitHalfOpen {
+			cb.state = CircuitOpen
+		}
+		return
+	}
+
+	if err == nil {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.threshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+func (cb *CircuitBreakerStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	if err := cb.before(); err != nil {
+		return 0, err
+	}
+	val, err := cb.storage.IncrementCounter(ctx, key)
+	cb.after(err)
+	return val, err
+}
+
+func (cb *CircuitBreakerStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	incrementer, ok := cb.storage.(BatchIncrementer)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return 0, err
+	}
+	val, err := incrementer.IncrementCounterBy(ctx, key, n)
+	cb.after(err)
+	return val, err
+}
+
+func (cb *CircuitBreakerStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	consumer, ok := cb.storage.(AtomicConsumer)
+	if !ok {
+		return 0, false, errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return 0, false, err
+	}
+	counter, consumed, err := consumer.Consume(ctx, key, cost, limit, ttlSeconds)
+	cb.after(err)
+	return counter, consumed, err
+}
+
+func (cb *CircuitBreakerStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := cb.storage.SetExpiration(ctx, key, ttlSeconds)
+	cb.after(err)
+	return err
+}
+
+func (cb *CircuitBreakerStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reader, ok := cb.storage.(TTLReader)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return 0, err
+	}
+	ttl, err := reader.TTL(ctx, key)
+	cb.after(err)
+	return ttl, err
+}
+
+func (cb *CircuitBreakerStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	if err := cb.before(); err != nil {
+		return 0, err
+	}
+	val, err := cb.storage.GetCounter(ctx, key)
+	cb.after(err)
+	return val, err
+}
+
+func (cb *CircuitBreakerStorage) Exists(ctx context.Context, key string) (bool, error) {
+	if err := cb.before(); err != nil {
+		return false, err
+	}
+	exists, err := cb.storage.Exists(ctx, key)
+	cb.after(err)
+	return exists, err
+}
+
+func (cb *CircuitBreakerStorage) Delete(ctx context.Context, key string) error {
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := cb.storage.Delete(ctx, key)
+	cb.after(err)
+	return err
+}
+
+// Unwrap returns the wrapped storage
+func (cb *CircuitBreakerStorage) Unwrap() StorageStrategy {
+	return cb.storage
+}
+
+func (cb *CircuitBreakerStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error) {
+	leases, ok := cb.storage.(LeaseStorage)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return false, err
+	}
+	acquired, err := leases.AcquireLease(ctx, key, leaseID, limit, lease)
+	cb.after(err)
+	return acquired, err
+}
+
+func (cb *CircuitBreakerStorage) RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error {
+	leases, ok := cb.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := leases.RenewLease(ctx, key, leaseID, lease)
+	cb.after(err)
+	return err
+}
+
+func (cb *CircuitBreakerStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	leases, ok := cb.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := leases.ReleaseLease(ctx, key, leaseID)
+	cb.after(err)
+	return err
+}
+
+func (cb *CircuitBreakerStorage) AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error {
+	usage, ok := cb.storage.(UsageStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return err
+	}
+	err := usage.AddUsage(ctx, hour, totals, retention)
+	cb.after(err)
+	return err
+}
+
+func (cb *CircuitBreakerStorage) Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error) {
+	usage, ok := cb.storage.(UsageStorage)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := cb.before(); err != nil {
+		return nil, err
+	}
+	buckets, err := usage.Usage(ctx, from, to)
+	cb.after(err)
+	return buckets, err
+}
+
+// Ping checks the wrapped storage directly, bypassing the breaker, so health
+// checks keep reporting the real storage state while the circuit is open
+func (cb *CircuitBreakerStorage) Ping(ctx context.Context) error {
+	return Ping(ctx, cb.storage)
+}
+
+func (cb *CircuitBreakerStorage) Close() error {
+	return cb.storage.Close()
+}
@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyStorage fails every call while down is true
+type flakyStorage struct {
+	*MemoryStorage
+	down  bool
+	calls int
+}
+
+func (f *flakyStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	f.calls++
+	if f.down {
+		return 0, errors.New("storage down")
+	}
+	return f.MemoryStorage.GetCounter(ctx, key)
+}
+
+// TestCircuitBreakerOpensAfterThreshold tests that the breaker stops calling a failing storage
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	storage := &flakyStorage{MemoryStorage: NewMemoryStorage(), down: true}
+	breaker := NewCircuitBreakerStorage(storage, 3, 30)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := breaker.GetCounter(ctx, "key"); err == nil {
+			t.Fatalf("Call %d should have failed", i+1)
+		}
+	}
+
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("Expected open circuit, got %s", breaker.State())
+	}
+
+	if _, err := breaker.GetCounter(ctx, "key"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if storage.calls != 3 {
+		t.Fatalf("Expected 3 calls to reach the storage, got %d", storage.calls)
+	}
+}
+
+// TestCircuitBreakerRecovers tests that a successful probe closes the breaker
+func TestCircuitBreakerRecovers(t *testing.T) {
+	storage := &flakyStorage{MemoryStorage: NewMemoryStorage(), down: true}
+	breaker := NewCircuitBreakerStorage(storage, 1, 0)
+	ctx := context.Background()
+
+	if _, err := breaker.GetCounter(ctx, "key"); err == nil {
+		t.Fatal("Call should have failed")
+	}
+
+	// Open timeout of zero lets the next call probe immediately
+	storage.down = false
+	time.Sleep(time.Millisecond)
+
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("Expected half-open circuit, got %s", breaker.State())
+	}
+	if _, err := breaker.GetCounter(ctx, "key"); err != nil {
+		t.Fatalf("Probe should have succeeded: %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("Expected closed circuit, got %s", breaker.State())
+	}
+}
@@ -0,0 +1,87 @@
+package strategy_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy/strategytest"
+)
+
+// TestMemoryConformance runs the storage suite against the memory backend
+func TestMemoryConformance(t *testing.T) {
+	strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+		now := clock.NewFake(time.Now())
+		return strategytest.Harness{Storage: strategy.NewMemoryStorageWithClock(now), Advance: now.Advance}
+	})
+}
+
+// TestRedisConformance runs the storage suite against an embedded Redis
+func TestRedisConformance(t *testing.T) {
+	strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+		server := miniredis.RunT(t)
+		storage := strategy.NewRedisStorageFromClient(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+		return strategytest.Harness{Storage: storage, Advance: server.FastForward}
+	})
+}
+
+// TestCircuitBreakerConformance runs the storage suite through a closed circuit breaker
+func TestCircuitBreakerConformance(t *testing.T) {
+	strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+		now := clock.NewFake(time.Now())
+		storage := strategy.NewCircuitBreakerStorage(strategy.NewMemoryStorageWithClock(now), 5, 30)
+		return strategytest.Harness{Storage: storage, Advance: now.Advance}
+	})
+}
+
+// TestCachedStorageConformance runs the storage suite through the local cache,
+// with and without batched increments
+func TestCachedStorageConformance(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		batchSize int
+	}{
+		{"Unbatched", 0},
+		{"Batched", 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+				now := clock.NewFake(time.Now())
+				storage := strategy.NewCachedStorageWithClock(strategy.NewMemoryStorageWithClock(now), tt.batchSize, 100, 1000, now)
+				return strategytest.Harness{Storage: storage, Advance: now.Advance}
+			})
+		})
+	}
+}
+
+// TestFaultStorageConformance runs the storage suite through a fault storage
+// that injects nothing
+func TestFaultStorageConformance(t *testing.T) {
+	strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+		now := clock.NewFake(time.Now())
+		storage := strategy.NewFaultStorage(strategy.NewMemoryStorageWithClock(now), strategy.FaultConfig{})
+		return strategytest.Harness{Storage: storage, Advance: now.Advance}
+	})
+}
+
+// TestRealRedisConformance runs the storage suite against a real Redis when
+// REDIS_URL is set, waiting on the wall clock for keys to expire
+func TestRealRedisConformance(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set")
+	}
+
+	strategytest.Run(t, func(t *testing.T) strategytest.Harness {
+		storage, err := strategy.NewRedisStorageWithOptions(strategy.RedisOptions{URL: url})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return strategytest.Harness{Storage: storage}
+	})
+}
@@ -0,0 +1,92 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testConsume checks that concurrent consumers never overshoot the limit
+func testConsume(t *testing.T, storage AtomicConsumer, key string) {
+	ctx := context.Background()
+
+	var consumed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := storage.Consume(ctx, key, 3, 10, 60); err == nil && ok {
+				atomic.AddInt32(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if consumed != 3 {
+		t.Fatalf("Expected exactly 3 consumptions of 3 units within 10, got %d", consumed)
+	}
+
+	// What is left (1 unit) can still be taken, a zero cost only checks
+	if counter, ok, _ := storage.Consume(ctx, key, 1, 10, 60); !ok || counter != 10 {
+		t.Fatalf("Expected the last unit to be consumed, got %d %t", counter, ok)
+	}
+	if counter, ok, _ := storage.Consume(ctx, key, 0, 10, 60); ok || counter != 10 {
+		t.Fatalf("Zero cost should be denied once the limit is reached, got %d %t", counter, ok)
+	}
+}
+
+// TestMemoryConsume tests atomic consumption in memory, including the window TTL
+func TestMemoryConsume(t *testing.T) {
+	storage := NewMemoryStorage()
+	testConsume(t, storage, "limiter:ip:{10.0.0.1}")
+
+	ttl, _ := storage.TTL(context.Background(), "limiter:ip:{10.0.0.1}")
+	if ttl <= 0 || ttl > 60*time.Second {
+		t.Fatalf("Expected the window TTL to be set, got %v", ttl)
+	}
+}
+
+// TestRedisConsume runs against a real Redis when REDIS_URL is set
+func TestRedisConsume(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set")
+	}
+
+	storage, err := NewRedisStorageWithOptions(RedisOptions{URL: url})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer storage.Close()
+
+	key := "limiter:test:{consume}"
+	storage.Delete(context.Background(), key)
+	defer storage.Delete(context.Background(), key)
+
+	testConsume(t, storage, key)
+}
+
+// TestDecoratorsForwardConsume tests that decorators forward or refuse atomic consumption
+func TestDecoratorsForwardConsume(t *testing.T) {
+	ctx := context.Background()
+
+	breaker := NewCircuitBreakerStorage(NewMemoryStorage(), 5, 30)
+	if _, ok, err := breaker.Consume(ctx, "key", 1, 1, 60); err != nil || !ok {
+		t.Fatalf("Breaker should forward Consume, got %t %v", ok, err)
+	}
+
+	cached := NewCachedStorage(breaker, 0, 100, 100)
+	if _, ok, _ := cached.Consume(ctx, "key", 1, 1, 60); ok {
+		t.Fatal("Limit reached, consumption should be refused")
+	}
+
+	batched := NewCachedStorage(NewMemoryStorage(), 10, 100, 100)
+	if _, _, err := batched.Consume(ctx, "key", 1, 1, 60); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Batching cache should not support Consume, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by calls that a FaultStorage made fail
+var ErrInjectedFault = errors.New("injected storage fault")
+
+// FaultConfig describes the faults injected by a FaultStorage. Rates are
+// probabilities between 0 and 1, drawn independently on every call.
+type FaultConfig struct {
+	// Latency is added to every call, plus a random delay of up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// ErrorRate fails calls before they reach the storage
+	ErrorRate float64
+
+	// TimeoutRate makes calls hang for Timeout, or until the context is
+	// done, and then fail with context.DeadlineExceeded
+	TimeoutRate float64
+	Timeout     time.Duration
+
+	// LostReplyRate applies writes to the storage but fails them anyway, like
+	// a reply lost after the command ran
+	LostReplyRate float64
+
+	// ExpireErrorRate fails SetExpiration calls only, e.g. an INCR that
+	// succeeds followed by an EXPIRE that does not
+	ExpireErrorRate float64
+
+	// SplitConsume makes Consume unsupported, so the limiter falls back to
+	// separate increment and expiration calls where partial failures happen
+	SplitConsume bool
+
+	Seed int64
+}
+
+// Validate checks that the rates are probabilities
+func (c FaultConfig) Validate() error {
+	for name, rate := range map[string]float64{
+		"error rate":        c.ErrorRate,
+		"timeout rate":      c.TimeoutRate,
+		"lost reply rate":   c.LostReplyRate,
+		"expire error rate": c.ExpireErrorRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("fault injection %s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if c.Latency < 0 || c.Jitter < 0 || c.Timeout < 0 {
+		return fmt.Errorf("fault injection delays must not be negative")
+	}
+	return nil
+}
+
+// FaultStorage wraps a StorageStrategy and injects latency, errors, timeouts
+// and partial failures, to test the limiter against a degraded storage
+type FaultStorage struct {
+	storage StorageStrategy
+
+	mu     sync.Mutex
+	config FaultConfig
+	rand   *rand.Rand
+}
+
+// NewFaultStorage creates a storage that injects the configured faults in
+// calls to the wrapped storage
+func NewFaultStorage(storage StorageStrategy, config FaultConfig) *FaultStorage {
+	return &FaultStorage{
+		storage: storage,
+		config:  config,
+		rand:    rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// SetConfig replaces the injected faults, e.g. to stop injecting them
+func (f *FaultStorage) SetConfig(config FaultConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+}
+
+// draw reports whether an event of the given rate happens on this call
+func (f *FaultStorage) draw(rate func(FaultConfig) float64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := rate(f.config)
+	return r > 0 && f.rand.Float64() < r
+}
+
+// before delays the call and decides whether it fails before reaching the storage
+func (f *FaultStorage) before(ctx context.Context) error {
+	f.mu.Lock()
+	config := f.config
+	delay := config.Latency
+	if config.Jitter > 0 {
+		delay += time.Duration(f.rand.Int63n(int64(config.Jitter)))
+	}
+	fail := config.ErrorRate > 0 && f.rand.Float64() < config.ErrorRate
+	timeout := config.TimeoutRate > 0 && f.rand.Float64() < config.TimeoutRate
+	f.mu.Unlock()
+
+	if timeout {
+		delay += config.Timeout
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case timeout:
+		return fmt.Errorf("%w: %w", ErrInjectedFault, context.DeadlineExceeded)
+	case fail:
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// lostReply decides whether a write that went through reports a failure
+func (f *FaultStorage) lostReply() error {
+	if f.draw(func(c FaultConfig) float64 { return c.LostReplyRate }) {
+		return fmt.Errorf("%w: reply lost", ErrInjectedFault)
+	}
+	return nil
+}
+
+func (f *FaultStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	if err := f.before(ctx); err != nil {
+		return 0, err
+	}
+	val, err := f.storage.IncrementCounter(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if err := f.lostReply(); err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
+func (f *FaultStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	incrementer, ok := f.storage.(BatchIncrementer)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return 0, err
+	}
+	val, err := incrementer.IncrementCounterBy(ctx, key, n)
+	if err != nil {
+		return 0, err
+	}
+	if err := f.lostReply(); err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
+func (f *FaultStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	consumer, ok := f.storage.(AtomicConsumer)
+	f.mu.Lock()
+	split := f.config.SplitConsume
+	f.mu.Unlock()
+	if !ok || split {
+		return 0, false, errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return 0, false, err
+	}
+	counter, consumed, err := consumer.Consume(ctx, key, cost, limit, ttlSeconds)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := f.lostReply(); err != nil {
+		return 0, false, err
+	}
+	return counter, consumed, nil
+}
+
+func (f *FaultStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	if f.draw(func(c FaultConfig) float64 { return c.ExpireErrorRate }) {
+		return fmt.Errorf("%w: expire failed", ErrInjectedFault)
+	}
+	if err := f.storage.SetExpiration(ctx, key, ttlSeconds); err != nil {
+		return err
+	}
+	return f.lostReply()
+}
+
+func (f *FaultStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reader, ok := f.storage.(TTLReader)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return 0, err
+	}
+	return reader.TTL(ctx, key)
+}
+
+func (f *FaultStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	if err := f.before(ctx); err != nil {
+		return 0, err
+	}
+	return f.storage.GetCounter(ctx, key)
+}
+
+func (f *FaultStorage) Exists(ctx context.Context, key string) (bool, error) {
+	if err := f.before(ctx); err != nil {
+		return false, err
+	}
+	return f.storage.Exists(ctx, key)
+}
+
+func (f *FaultStorage) Delete(ctx context.Context, key string) error {
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	if err := f.storage.Delete(ctx, key); err != nil {
+		return err
+	}
+	return f.lostReply()
+}
+
+// Unwrap returns the wrapped storage
+func (f *FaultStorage) Unwrap() StorageStrategy {
+	return f.storage
+}
+
+func (f *FaultStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error) {
+	leases, ok := f.storage.(LeaseStorage)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return false, err
+	}
+	acquired, err := leases.AcquireLease(ctx, key, leaseID, limit, lease)
+	if err != nil {
+		return false, err
+	}
+	if err := f.lostReply(); err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+func (f *FaultStorage) RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error {
+	leases, ok := f.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	if err := leases.RenewLease(ctx, key, leaseID, lease); err != nil {
+		return err
+	}
+	return f.lostReply()
+}
+
+func (f *FaultStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	leases, ok := f.storage.(LeaseStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	if err := leases.ReleaseLease(ctx, key, leaseID); err != nil {
+		return err
+	}
+	return f.lostReply()
+}
+
+func (f *FaultStorage) AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error {
+	usage, ok := f.storage.(UsageStorage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	if err := usage.AddUsage(ctx, hour, totals, retention); err != nil {
+		return err
+	}
+	return f.lostReply()
+}
+
+func (f *FaultStorage) Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error) {
+	usage, ok := f.storage.(UsageStorage)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	if err := f.before(ctx); err != nil {
+		return nil, err
+	}
+	return usage.Usage(ctx, from, to)
+}
+
+// Ping goes through the injected faults, so readiness checks see the degraded storage
+func (f *FaultStorage) Ping(ctx context.Context) error {
+	if err := f.before(ctx); err != nil {
+		return err
+	}
+	return Ping(ctx, f.storage)
+}
+
+func (f *FaultStorage) Close() error {
+	return f.storage.Close()
+}
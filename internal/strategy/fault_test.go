@@ -0,0 +1,111 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestFaultStorageErrors tests that failed calls never reach the storage
+func TestFaultStorageErrors(t *testing.T) {
+	memory := NewMemoryStorage()
+	storage := NewFaultStorage(memory, FaultConfig{ErrorRate: 1})
+	ctx := context.Background()
+
+	if _, err := storage.IncrementCounter(ctx, "key"); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected ErrInjectedFault, got %v", err)
+	}
+	if counter, _ := memory.GetCounter(ctx, "key"); counter != 0 {
+		t.Fatalf("Expected the increment not to be applied, got %d", counter)
+	}
+
+	storage.SetConfig(FaultConfig{})
+	if counter, err := storage.IncrementCounter(ctx, "key"); err != nil || counter != 1 {
+		t.Fatalf("Expected 1 once faults stop, got %d, %v", counter, err)
+	}
+}
+
+// TestFaultStorageLostReply tests that writes with a lost reply are applied anyway
+func TestFaultStorageLostReply(t *testing.T) {
+	memory := NewMemoryStorage()
+	storage := NewFaultStorage(memory, FaultConfig{LostReplyRate: 1})
+	ctx := context.Background()
+
+	if _, err := storage.IncrementCounter(ctx, "key"); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected ErrInjectedFault, got %v", err)
+	}
+	if counter, _ := memory.GetCounter(ctx, "key"); counter != 1 {
+		t.Fatalf("Expected the increment to be applied, got %d", counter)
+	}
+
+	// Reads have no reply to lose
+	if counter, err := storage.GetCounter(ctx, "key"); err != nil || counter != 1 {
+		t.Fatalf("Expected 1, got %d, %v", counter, err)
+	}
+}
+
+// TestFaultStoragePartialFailure tests that an increment can succeed while the expiration fails
+func TestFaultStoragePartialFailure(t *testing.T) {
+	memory := NewMemoryStorage()
+	storage := NewFaultStorage(memory, FaultConfig{ExpireErrorRate: 1, SplitConsume: true})
+	ctx := context.Background()
+
+	if _, _, err := storage.Consume(ctx, "key", 1, 5, 60); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("Expected Consume to be unsupported, got %v", err)
+	}
+	if _, err := storage.IncrementCounter(ctx, "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := storage.SetExpiration(ctx, "key", 60); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected ErrInjectedFault, got %v", err)
+	}
+	if ttl, _ := memory.TTL(ctx, "key"); ttl != 0 {
+		t.Fatalf("Expected the key to be left without a TTL, got %v", ttl)
+	}
+}
+
+// TestFaultStorageTimeout tests that timeouts fail with DeadlineExceeded and honor the context
+func TestFaultStorageTimeout(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage(), FaultConfig{TimeoutRate: 1, Timeout: 10 * time.Millisecond})
+
+	if _, err := storage.GetCounter(context.Background(), "key"); !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected an injected DeadlineExceeded, got %v", err)
+	}
+
+	storage.SetConfig(FaultConfig{TimeoutRate: 1, Timeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := storage.GetCounter(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the call to stop with the context, took %v", elapsed)
+	}
+}
+
+// TestFaultStorageLatency tests that latency is added to every call
+func TestFaultStorageLatency(t *testing.T) {
+	storage := NewFaultStorage(NewMemoryStorage(), FaultConfig{Latency: 20 * time.Millisecond, Jitter: 5 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := storage.Exists(context.Background(), "key"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("Expected at least 20ms, took %v", elapsed)
+	}
+}
+
+func TestFaultConfigValidate(t *testing.T) {
+	if err := (FaultConfig{ErrorRate: 0.1, LostReplyRate: 1}).Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := (FaultConfig{TimeoutRate: 1.5}).Validate(); err == nil {
+		t.Fatal("Expected error for a rate above 1")
+	}
+	if err := (FaultConfig{Latency: -time.Second}).Validate(); err == nil {
+		t.Fatal("Expected error for a negative latency")
+	}
+}
@@ -0,0 +1,359 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+)
+
+// ErrMemoryClosed is returned by a memory storage after Close
+var ErrMemoryClosed = errors.New("memory storage is closed")
+
+// memorySweepInterval is how often writes also drop every expired counter,
+// lease and usage bucket, not only the keys they touch
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	value     int
+	expiresAt time.Time
+}
+
+// MemoryStorage keeps counters in process memory, so limits are enforced per
+// instance. Expired keys are swept periodically, so keys that are never used
+// again (e.g. clients seen during an outage) do not pile up.
+type MemoryStorage struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	leases  map[string]map[string]time.Time
+	usage   map[int64]*memoryUsage
+	clock   clock.Clock
+	sweptAt time.Time
+	closed  bool
+}
+
+type memoryUsage struct {
+	tokens    map[string]UsageTotals
+	expiresAt time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return NewMemoryStorageWithClock(clock.Real)
+}
+
+// NewMemoryStorageWithClock creates a memory storage that expires keys
+// according to the clock instead of the wall clock, e.g. to replay traffic
+// or to test expiry without sleeping
+func NewMemoryStorageWithClock(clock clock.Clock) *MemoryStorage {
+	return &MemoryStorage{
+		entries: make(map[string]*memoryEntry),
+		leases:  make(map[string]map[string]time.Time),
+		usage:   make(map[int64]*memoryUsage),
+		clock:   clock,
+		sweptAt: clock.Now(),
+	}
+}
+
+// sweep drops everything that expired, at most once per memorySweepInterval;
+// the caller must hold the lock
+func (m *MemoryStorage) sweep() {
+	now := m.clock.Now()
+	if now.Sub(m.sweptAt) < memorySweepInterval {
+		return
+	}
+	m.sweptAt = now
+
+	for key := range m.entries {
+		m.get(key)
+	}
+	for key := range m.leases {
+		m.liveLeases(key)
+	}
+	for hour, bucket := range m.usage {
+		if !now.Before(bucket.expiresAt) {
+			delete(m.usage, hour)
+		}
+	}
+}
+
+// usable fails calls made with a done context or after Close, like a remote storage would
+func (m *MemoryStorage) usable(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if m.closed {
+		return ErrMemoryClosed
+	}
+	return nil
+}
+
+// get returns the live entry for a key, dropping it if it has expired
+func (m *MemoryStorage) get(key string) *memoryEntry {
+	entry, exists := m.entries[key]
+	if !exists {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !m.clock.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil
+	}
+	return entry
+}
+
+func (m *MemoryStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
+	return m.IncrementCounterBy(ctx, key, 1)
+}
+
+func (m *MemoryStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return 0, err
+	}
+	m.sweep()
+
+	entry := m.get(key)
+	if entry == nil {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	entry.value += n
+	return entry.value, nil
+}
+
+func (m *MemoryStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return 0, false, err
+	}
+	m.sweep()
+
+	entry := m.get(key)
+	counter := 0
+	if entry != nil {
+		counter = entry.value
+	}
+	if counter >= limit || counter+cost > limit {
+		return counter, false, nil
+	}
+	if cost == 0 {
+		return counter, true, nil
+	}
+
+	if entry == nil {
+		entry = &memoryEntry{}
+		m.entries[key] = entry
+	}
+	entry.value += cost
+	if entry.expiresAt.IsZero() {
+		entry.expiresAt = m.clock.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	}
+	return entry.value, true, nil
+}
+
+func (m *MemoryStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return err
+	}
+
+	if entry := m.get(key); entry != nil {
+		entry.expiresAt = m.clock.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return 0, err
+	}
+
+	entry := m.get(key)
+	if entry == nil || entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(m.clock.Now()), nil
+}
+
+func (m *MemoryStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return 0, err
+	}
+
+	if entry := m.get(key); entry != nil {
+		return entry.value, nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryStorage) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return false, err
+	}
+
+	return m.get(key) != nil, nil
+}
+
+func (m *MemoryStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return err
+	}
+
+	delete(m.entries, key)
+	return nil
+}
+
+// liveLeases returns the leases of a key, dropping the expired ones
+func (m *MemoryStorage) liveLeases(key string) map[string]time.Time {
+	leases := m.leases[key]
+	now := m.clock.Now()
+	for id, expiresAt := range leases {
+		if !now.Before(expiresAt) {
+			delete(leases, id)
+		}
+	}
+	if len(leases) == 0 {
+		delete(m.leases, key)
+		return nil
+	}
+	return leases
+}
+
+func (m *MemoryStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return false, err
+	}
+	m.sweep()
+
+	leases := m.liveLeases(key)
+	if len(leases) >= limit {
+		return false, nil
+	}
+	if leases == nil {
+		leases = make(map[string]time.Time)
+		m.leases[key] = leases
+	}
+	leases[leaseID] = m.clock.Now().Add(lease)
+	return true, nil
+}
+
+func (m *MemoryStorage) RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return err
+	}
+
+	if leases := m.liveLeases(key); leases != nil {
+		if _, held := leases[leaseID]; held {
+			leases[leaseID] = m.clock.Now().Add(lease)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return err
+	}
+
+	if leases := m.leases[key]; leases != nil {
+		delete(leases, leaseID)
+		if len(leases) == 0 {
+			delete(m.leases, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return err
+	}
+	m.sweep()
+
+	bucket := m.usage[hour.Unix()]
+	if bucket == nil {
+		bucket = &memoryUsage{tokens: make(map[string]UsageTotals)}
+		m.usage[hour.Unix()] = bucket
+	}
+	for token, add := range totals {
+		current := bucket.tokens[token]
+		current.Allowed += add.Allowed
+		current.Denied += add.Denied
+		current.Cost += add.Cost
+		bucket.tokens[token] = current
+	}
+	bucket.expiresAt = m.clock.Now().Add(retention)
+	return nil
+}
+
+func (m *MemoryStorage) Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.usable(ctx); err != nil {
+		return nil, err
+	}
+
+	var buckets []UsageBucket
+	now := m.clock.Now()
+	for hour := from.Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		bucket := m.usage[hour.Unix()]
+		if bucket == nil {
+			continue
+		}
+		if !now.Before(bucket.expiresAt) {
+			delete(m.usage, hour.Unix())
+			continue
+		}
+
+		tokens := make(map[string]UsageTotals, len(bucket.tokens))
+		for token, totals := range bucket.tokens {
+			tokens[token] = totals
+		}
+		buckets = append(buckets, UsageBucket{Hour: hour.UTC(), Tokens: tokens})
+	}
+	return buckets, nil
+}
+
+func (m *MemoryStorage) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Calls fail after Close, nothing needs the keys anymore
+	m.closed = true
+	m.entries = make(map[string]*memoryEntry)
+	m.leases = make(map[string]map[string]time.Time)
+	m.usage = make(map[int64]*memoryUsage)
+	return nil
+}
@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+)
+
+// TestMemoryStorageSweepsExpiredKeys tests that keys which are never touched
+// again are dropped once expired, so memory does not grow with every client seen
+func TestMemoryStorageSweepsExpiredKeys(t *testing.T) {
+	now := clock.NewFake(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	storage := NewMemoryStorageWithClock(now)
+	defer storage.Close()
+
+	ctx := context.Background()
+	hour := now.Now().Truncate(time.Hour)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("limiter:ip:{10.0.0.%d}", i)
+		if _, _, err := storage.Consume(ctx, key, 1, 5, 1); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := storage.AcquireLease(ctx, "limiter:concurrency:*:"+key, "lease", 1, time.Second); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := storage.AddUsage(ctx, hour.Add(time.Duration(-i)*time.Hour), map[string]UsageTotals{"acme": {Allowed: 1}}, time.Second); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	now.Advance(memorySweepInterval)
+	if _, err := storage.IncrementCounter(ctx, "limiter:ip:{10.0.1.1}"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if len(storage.entries) != 1 || len(storage.leases) != 0 || len(storage.usage) != 0 {
+		t.Fatalf("Expected only the live key to remain, got %d counters, %d leases and %d usage buckets", len(storage.entries), len(storage.leases), len(storage.usage))
+	}
+}
@@ -3,32 +3,51 @@ package strategy
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisStorage stores counters in a standalone, sentinel-managed or clustered Redis
 type RedisStorage struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
 func NewRedisStorage(host string, port int, db int) (*RedisStorage, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%d", host, port),
+	return NewRedisStorageWithOptions(RedisOptions{
+		Host: host,
+		Port: port,
 		DB:   db,
 	})
+}
+
+// NewRedisStorageWithOptions connects to Redis using the full set of connection options
+func NewRedisStorageWithOptions(opts RedisOptions) (*RedisStorage, error) {
+	client, err := opts.NewClient()
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	return NewRedisStorageFromClient(client), nil
+}
+
+// NewRedisStorageFromClient wraps an existing go-redis client (single node,
+// failover or cluster) without testing the connection
+func NewRedisStorageFromClient(client redis.UniversalClient) *RedisStorage {
 	return &RedisStorage{
 		client: client,
-	}, nil
+	}
 }
 
 func (r *RedisStorage) IncrementCounter(ctx context.Context, key string) (int, error) {
@@ -39,10 +58,166 @@ func (r *RedisStorage) IncrementCounter(ctx context.Context, key string) (int, e
 	return int(val), nil
 }
 
+func (r *RedisStorage) IncrementCounterBy(ctx context.Context, key string, n int) (int, error) {
+	val, err := r.client.IncrBy(ctx, key, int64(n)).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(val), nil
+}
+
+// consumeScript adds the cost only when it fits in the limit and starts the
+// window TTL on the first consumption, all in one round trip
+var consumeScript = redis.NewScript(`
+local counter = tonumber(redis.call('GET', KEYS[1]) or '0')
+local cost = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+if counter >= limit or counter + cost > limit then
+	return {counter, 0}
+end
+if cost == 0 then
+	return {counter, 1}
+end
+counter = redis.call('INCRBY', KEYS[1], cost)
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[3])
+end
+return {counter, 1}
+`)
+
+func (r *RedisStorage) Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error) {
+	result, err := consumeScript.Run(ctx, r.client, []string{key}, cost, limit, ttlSeconds).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	return int(result[0]), result[1] == 1, nil
+}
+
+// Leases are members of a sorted set scored by their expiry in milliseconds.
+// Expiries come from the instance clocks, which must be kept in sync.
+var acquireLeaseScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return 1
+`)
+
+var renewLeaseScript = redis.NewScript(`
+if redis.call('ZADD', KEYS[1], 'XX', 'CH', ARGV[1], ARGV[2]) == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+func (r *RedisStorage) AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error) {
+	now := time.Now()
+	acquired, err := acquireLeaseScript.Run(ctx, r.client, []string{key},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), limit, leaseID, lease.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+func (r *RedisStorage) RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error {
+	return renewLeaseScript.Run(ctx, r.client, []string{key},
+		time.Now().Add(lease).UnixMilli(), leaseID, lease.Milliseconds()).Err()
+}
+
+func (r *RedisStorage) ReleaseLease(ctx context.Context, key string, leaseID string) error {
+	return r.client.ZRem(ctx, key, leaseID).Err()
+}
+
+// usageKey is the hash holding the usage of every token in one hour, with
+// fields "allowed:<token>", "denied:<token>" and "cost:<token>"
+func usageKey(hour time.Time) string {
+	return fmt.Sprintf("limiter:usage:%d", hour.Unix())
+}
+
+func (r *RedisStorage) AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error {
+	key := usageKey(hour)
+	// MULTI/EXEC applies the whole flush or nothing, so a failed flush can be retried without double counting
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for token, add := range totals {
+			if add.Allowed != 0 {
+				pipe.HIncrBy(ctx, key, "allowed:"+token, add.Allowed)
+			}
+			if add.Denied != 0 {
+				pipe.HIncrBy(ctx, key, "denied:"+token, add.Denied)
+			}
+			if add.Cost != 0 {
+				pipe.HIncrBy(ctx, key, "cost:"+token, add.Cost)
+			}
+		}
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStorage) Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error) {
+	var hours []time.Time
+	var results []*redis.MapStringStringCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for hour := from.Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+			hours = append(hours, hour.UTC())
+			results = append(results, pipe.HGetAll(ctx, usageKey(hour)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []UsageBucket
+	for i, result := range results {
+		fields := result.Val()
+		if len(fields) == 0 {
+			continue
+		}
+
+		tokens := make(map[string]UsageTotals)
+		for field, value := range fields {
+			counter, token, found := strings.Cut(field, ":")
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if !found || err != nil {
+				continue
+			}
+			totals := tokens[token]
+			switch counter {
+			case "allowed":
+				totals.Allowed = parsed
+			case "denied":
+				totals.Denied = parsed
+			case "cost":
+				totals.Cost = parsed
+			}
+			tokens[token] = totals
+		}
+		buckets = append(buckets, UsageBucket{Hour: hours[i], Tokens: tokens})
+	}
+	return buckets, nil
+}
+
 func (r *RedisStorage) SetExpiration(ctx context.Context, key string, ttlSeconds int) error {
 	return r.client.Expire(ctx, key, time.Duration(ttlSeconds)*time.Second).Err()
 }
 
+func (r *RedisStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Negative values mean the key is missing or has no expiration
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
 func (r *RedisStorage) GetCounter(ctx context.Context, key string) (int, error) {
 	val, err := r.client.Get(ctx, key).Int()
 	if err == redis.Nil {
@@ -63,6 +238,10 @@ func (r *RedisStorage) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
 
+func (r *RedisStorage) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *RedisStorage) Close() error {
 	return r.client.Close()
 }
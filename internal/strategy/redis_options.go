@@ -0,0 +1,261 @@
+package strategy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis deployment modes
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// RedisOptions configures the connection to Redis. When URL is set it is parsed
+// first (redis:// or rediss://) and the remaining non-zero fields override it.
+// Sentinel and cluster modes use Addrs instead of URL/Host/Port.
+type RedisOptions struct {
+	Mode string
+	URL  string
+	Host string
+	Port int
+	DB   int
+
+	// Sentinel and cluster nodes (host:port)
+	Addrs            []string
+	MasterName       string
+	SentinelUsername string
+	SentinelPassword string
+
+	// Authentication (Username is the ACL user, Redis 6+)
+	Username string
+	Password string
+
+	// TLS
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	// Pool tuning
+	PoolSize     int
+	MinIdleConns int
+	MaxRetries   int
+
+	// Timeouts in milliseconds (0 keeps the go-redis default)
+	DialTimeoutMs  int
+	ReadTimeoutMs  int
+	WriteTimeoutMs int
+}
+
+// mode returns the deployment mode, defaulting to standalone
+func (o RedisOptions) mode() string {
+	if o.Mode == "" {
+		return RedisModeStandalone
+	}
+	return o.Mode
+}
+
+// Validate checks the options for inconsistent values
+func (o RedisOptions) Validate() error {
+	switch o.mode() {
+	case RedisModeStandalone:
+		if o.URL == "" && o.Host == "" {
+			return errors.New("redis: either URL or host must be set")
+		}
+		if o.URL == "" && (o.Port < 1 || o.Port > 65535) {
+			return fmt.Errorf("redis: invalid port %d", o.Port)
+		}
+	case RedisModeSentinel:
+		if len(o.Addrs) == 0 || o.MasterName == "" {
+			return errors.New("redis: sentinel mode requires sentinel addresses and a master name")
+		}
+	case RedisModeCluster:
+		if len(o.Addrs) == 0 {
+			return errors.New("redis: cluster mode requires node addresses")
+		}
+		if o.DB != 0 {
+			return errors.New("redis: cluster mode only supports db 0")
+		}
+	default:
+		return fmt.Errorf("redis: invalid mode %q (expected standalone, sentinel or cluster)", o.Mode)
+	}
+	if o.DB < 0 {
+		return fmt.Errorf("redis: invalid db %d", o.DB)
+	}
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return errors.New("redis: TLS client certificate and key must be set together")
+	}
+	if o.PoolSize < 0 || o.MinIdleConns < 0 {
+		return errors.New("redis: pool size and min idle connections must not be negative")
+	}
+	if o.PoolSize > 0 && o.MinIdleConns > o.PoolSize {
+		return fmt.Errorf("redis: min idle connections (%d) exceed pool size (%d)", o.MinIdleConns, o.PoolSize)
+	}
+	if o.DialTimeoutMs < 0 || o.ReadTimeoutMs < 0 || o.WriteTimeoutMs < 0 {
+		return errors.New("redis: timeouts must not be negative")
+	}
+	return nil
+}
+
+// tlsEnabled reports whether the connection must use TLS
+func (o RedisOptions) tlsEnabled() bool {
+	return o.TLSEnabled || o.TLSCAFile != "" || o.TLSCertFile != "" || o.TLSInsecureSkipVerify
+}
+
+// ClientOptions validates the options and converts them to go-redis options
+func (o RedisOptions) ClientOptions() (*redis.Options, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := &redis.Options{
+		Addr: fmt.Sprintf("%s:%d", o.Host, o.Port),
+		DB:   o.DB,
+	}
+
+	if o.URL != "" {
+		parsed, err := redis.ParseURL(o.URL)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid URL: %w", err)
+		}
+		opts = parsed
+		if o.DB != 0 {
+			opts.DB = o.DB
+		}
+	}
+
+	if o.Username != "" {
+		opts.Username = o.Username
+	}
+	if o.Password != "" {
+		opts.Password = o.Password
+	}
+
+	if o.tlsEnabled() {
+		tlsConfig, err := o.tlsConfig(opts.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig.ServerName == "" && o.Host != "" && o.URL == "" {
+			tlsConfig.ServerName = o.Host
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	if o.PoolSize > 0 {
+		opts.PoolSize = o.PoolSize
+	}
+	if o.MinIdleConns > 0 {
+		opts.MinIdleConns = o.MinIdleConns
+	}
+	if o.MaxRetries != 0 {
+		opts.MaxRetries = o.MaxRetries
+	}
+	if o.DialTimeoutMs > 0 {
+		opts.DialTimeout = time.Duration(o.DialTimeoutMs) * time.Millisecond
+	}
+	if o.ReadTimeoutMs > 0 {
+		opts.ReadTimeout = time.Duration(o.ReadTimeoutMs) * time.Millisecond
+	}
+	if o.WriteTimeoutMs > 0 {
+		opts.WriteTimeout = time.Duration(o.WriteTimeoutMs) * time.Millisecond
+	}
+
+	return opts, nil
+}
+
+// universalOptions converts the options for sentinel and cluster clients
+func (o RedisOptions) universalOptions() (*redis.UniversalOptions, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            o.Addrs,
+		DB:               o.DB,
+		Username:         o.Username,
+		Password:         o.Password,
+		MasterName:       o.MasterName,
+		SentinelUsername: o.SentinelUsername,
+		SentinelPassword: o.SentinelPassword,
+		PoolSize:         o.PoolSize,
+		MinIdleConns:     o.MinIdleConns,
+		MaxRetries:       o.MaxRetries,
+		DialTimeout:      time.Duration(o.DialTimeoutMs) * time.Millisecond,
+		ReadTimeout:      time.Duration(o.ReadTimeoutMs) * time.Millisecond,
+		WriteTimeout:     time.Duration(o.WriteTimeoutMs) * time.Millisecond,
+	}
+
+	// Host only applies to standalone: without a ServerName each node is
+	// verified against the host of its own address
+	if o.tlsEnabled() {
+		tlsConfig, err := o.tlsConfig(nil)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	return opts, nil
+}
+
+// NewClient creates the go-redis client for the configured mode
+func (o RedisOptions) NewClient() (redis.UniversalClient, error) {
+	switch o.mode() {
+	case RedisModeSentinel, RedisModeCluster:
+		opts, err := o.universalOptions()
+		if err != nil {
+			return nil, err
+		}
+		if o.mode() == RedisModeSentinel {
+			return redis.NewFailoverClient(opts.Failover()), nil
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		opts, err := o.ClientOptions()
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClient(opts), nil
+	}
+}
+
+// tlsConfig builds the TLS configuration, extending the one parsed from a rediss:// URL
+func (o RedisOptions) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		config = base.Clone()
+	}
+	config.InsecureSkipVerify = o.TLSInsecureSkipVerify
+
+	if o.TLSCAFile != "" {
+		caPEM, err := os.ReadFile(o.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("redis: no certificates found in CA file %s", o.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.TLSCertFile, o.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("redis: failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
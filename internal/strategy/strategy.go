@@ -1,6 +1,9 @@
 package strategy
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // StorageStrategy defines the interface for rate limiter storage strategies
 type StorageStrategy interface {
@@ -22,3 +25,107 @@ type StorageStrategy interface {
 	// Close closes the connection to the storage
 	Close() error
 }
+
+// Pinger is implemented by storages that can report their health
+type Pinger interface {
+	// Ping checks that the storage is reachable
+	Ping(ctx context.Context) error
+}
+
+// TTLReader is implemented by storages that can report the remaining lifetime of a key
+type TTLReader interface {
+	// TTL returns the remaining lifetime of a key, or zero if it does not exist or never expires
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// BatchIncrementer is implemented by storages that can add more than one to a counter
+type BatchIncrementer interface {
+	// IncrementCounterBy adds n to the counter for a key and returns the new value
+	IncrementCounterBy(ctx context.Context, key string, n int) (int, error)
+}
+
+// AtomicConsumer is implemented by storages that can check and consume units
+// in a single step, so concurrent callers never overshoot the limit
+type AtomicConsumer interface {
+	// Consume adds cost to the counter only if the result stays within limit,
+	// setting ttlSeconds when it starts a new window. It returns the counter
+	// after the call and whether the cost was consumed.
+	Consume(ctx context.Context, key string, cost int, limit int, ttlSeconds int) (int, bool, error)
+}
+
+// LeaseStorage is implemented by storages that can track in-flight requests.
+// Each request holds a lease that expires on its own, so slots held by a
+// crashed instance are freed after the lease duration.
+type LeaseStorage interface {
+	// AcquireLease adds the lease to the key if fewer than limit live leases are held
+	AcquireLease(ctx context.Context, key string, leaseID string, limit int, lease time.Duration) (bool, error)
+
+	// RenewLease extends a held lease, it does nothing if the lease already expired
+	RenewLease(ctx context.Context, key string, leaseID string, lease time.Duration) error
+
+	// ReleaseLease frees a lease
+	ReleaseLease(ctx context.Context, key string, leaseID string) error
+}
+
+// Wrapper is implemented by storages that decorate another storage, such as
+// the circuit breaker. They implement every optional interface and report
+// errors.ErrUnsupported when the wrapped storage does not.
+type Wrapper interface {
+	Unwrap() StorageStrategy
+}
+
+// SupportsLeases reports whether the storage, and every storage it wraps,
+// implements LeaseStorage
+func SupportsLeases(storage StorageStrategy) bool {
+	for {
+		if _, ok := storage.(LeaseStorage); !ok {
+			return false
+		}
+		wrapper, ok := storage.(Wrapper)
+		if !ok {
+			return true
+		}
+		storage = wrapper.Unwrap()
+	}
+}
+
+// UsageTotals are the usage counters of a token in one hour
+type UsageTotals struct {
+	Allowed int64
+	Denied  int64
+	// Cost is the number of units consumed by allowed requests
+	Cost int64
+}
+
+// UsageBucket holds the usage totals of every token seen in one hour
+type UsageBucket struct {
+	Hour   time.Time
+	Tokens map[string]UsageTotals
+}
+
+// UsageStorage is implemented by storages that keep usage totals per token
+// and hour, independently of the limiter counters that expire with their window
+type UsageStorage interface {
+	// AddUsage adds the totals of each token to the hour bucket, which is kept for retention
+	AddUsage(ctx context.Context, hour time.Time, totals map[string]UsageTotals, retention time.Duration) error
+
+	// Usage returns the non-empty hour buckets in [from, to), oldest first
+	Usage(ctx context.Context, from time.Time, to time.Time) ([]UsageBucket, error)
+}
+
+// BlockRecorder is implemented by storages that remember blocked keys locally,
+// so later checks for the same key can be answered without a round trip
+type BlockRecorder interface {
+	// RecordBlock notifies that the key reached its limit with the given counter
+	RecordBlock(ctx context.Context, key string, counter int)
+}
+
+// Ping checks the storage health, falling back to a lookup for storages that
+// do not implement Pinger
+func Ping(ctx context.Context, storage StorageStrategy) error {
+	if pinger, ok := storage.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	_, err := storage.Exists(ctx, "limiter:health")
+	return err
+}
@@ -0,0 +1,318 @@
+package strategytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// Harness is a storage under test
+type Harness struct {
+	Storage strategy.StorageStrategy
+	// Advance moves the storage time forward so keys expire, e.g. a fake
+	// clock or miniredis FastForward. When nil the suite sleeps instead.
+	Advance func(d time.Duration)
+}
+
+func (h Harness) advance(d time.Duration) {
+	if h.Advance == nil {
+		time.Sleep(d)
+		return
+	}
+	h.Advance(d)
+}
+
+// Run checks that a StorageStrategy behaves like the built-in backends. Each
+// test gets a fresh harness from newHarness, keys are namespaced so the
+// suite can also run against a shared Redis. Optional interfaces (TTLReader,
+// BatchIncrementer, AtomicConsumer, LeaseStorage, UsageStorage) are only
+// checked when the storage implements them.
+func Run(t *testing.T, newHarness func(t *testing.T) Harness) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, h Harness, key func(string) string)
+	}{
+		{"Increment", testIncrement},
+		{"Expiration", testExpiration},
+		{"ExistsAndDelete", testExistsAndDelete},
+		{"ConcurrentIncrements", testConcurrentIncrements},
+		{"CanceledContext", testCanceledContext},
+		{"IncrementBy", testIncrementBy},
+		{"Consume", testConsume},
+		{"Leases", testLeases},
+		{"Usage", testUsage},
+		{"Close", testClose},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+			prefix := fmt.Sprintf("limiter:conformance:%d:", time.Now().UnixNano())
+			key := func(name string) string {
+				return prefix + "{" + name + "}"
+			}
+			t.Cleanup(func() {
+				for _, name := range []string{"a", "b", "counter", "lease"} {
+					h.Storage.Delete(context.Background(), key(name))
+				}
+			})
+			tt.test(t, h, key)
+		})
+	}
+}
+
+func testIncrement(t *testing.T, h Harness, key func(string) string) {
+	ctx := context.Background()
+
+	if counter, err := h.Storage.GetCounter(ctx, key("a")); err != nil || counter != 0 {
+		t.Fatalf("Missing key should read as 0, got %d %v", counter, err)
+	}
+	for want := 1; want <= 3; want++ {
+		counter, err := h.Storage.IncrementCounter(ctx, key("a"))
+		if err != nil || counter != want {
+			t.Fatalf("Expected counter %d, got %d %v", want, counter, err)
+		}
+	}
+	if counter, err := h.Storage.GetCounter(ctx, key("a")); err != nil || counter != 3 {
+		t.Fatalf("Expected counter 3, got %d %v", counter, err)
+	}
+	if counter, _ := h.Storage.IncrementCounter(ctx, key("b")); counter != 1 {
+		t.Fatalf("Keys should have separate counters, got %d", counter)
+	}
+}
+
+func testExpiration(t *testing.T, h Harness, key func(string) string) {
+	ctx := context.Background()
+
+	h.Storage.IncrementCounter(ctx, key("a"))
+	h.Storage.IncrementCounter(ctx, key("a"))
+	if err := h.Storage.SetExpiration(ctx, key("a"), 2); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reader, ok := h.Storage.(strategy.TTLReader); ok {
+		if ttl, err := reader.TTL(ctx, key("a")); err != nil || ttl <= time.Second || ttl > 2*time.Second {
+			t.Fatalf("Expected a TTL of up to 2s, got %v %v", ttl, err)
+		}
+		if ttl, err := reader.TTL(ctx, key("b")); err != nil || ttl != 0 {
+			t.Fatalf("Missing key should have no TTL, got %v %v", ttl, err)
+		}
+	}
+
+	// Incrementing does not extend the expiration
+	h.advance(time.Second)
+	if counter, _ := h.Storage.IncrementCounter(ctx, key("a")); counter != 3 {
+		t.Fatalf("Key should live until it expires, got %d", counter)
+	}
+
+	h.advance(1100 * time.Millisecond)
+	if exists, err := h.Storage.Exists(ctx, key("a")); err != nil || exists {
+		t.Fatalf("Key should have expired, got %t %v", exists, err)
+	}
+	if counter, _ := h.Storage.IncrementCounter(ctx, key("a")); counter != 1 {
+		t.Fatalf("Expired key should start over, got %d", counter)
+	}
+
+	// Expiring a missing key is not an error and does not create it
+	if err := h.Storage.SetExpiration(ctx, key("b"), 60); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, _ := h.Storage.Exists(ctx, key("b")); exists {
+		t.Fatal("SetExpiration should not create a key")
+	}
+}
+
+func testExistsAndDelete(t *testing.T, h Harness, key func(string) string) {
+	ctx := context.Background()
+
+	if exists, err := h.Storage.Exists(ctx, key("a")); err != nil || exists {
+		t.Fatalf("Key should not exist yet, got %t %v", exists, err)
+	}
+	h.Storage.IncrementCounter(ctx, key("a"))
+	if exists, err := h.Storage.Exists(ctx, key("a")); err != nil || !exists {
+		t.Fatalf("Key should exist, got %t %v", exists, err)
+	}
+
+	if err := h.Storage.Delete(ctx, key("a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exists, _ := h.Storage.Exists(ctx, key("a")); exists {
+		t.Fatal("Deleted key should not exist")
+	}
+	if counter, _ := h.Storage.GetCounter(ctx, key("a")); counter != 0 {
+		t.Fatalf("Deleted key should read as 0, got %d", counter)
+	}
+	if err := h.Storage.Delete(ctx, key("b")); err != nil {
+		t.Fatalf("Deleting a missing key should not fail, got %v", err)
+	}
+}
+
+func testConcurrentIncrements(t *testing.T, h Harness, key func(string) string) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				h.Storage.IncrementCounter(ctx, key("counter"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if counter, err := h.Storage.GetCounter(ctx, key("counter")); err != nil || counter != 500 {
+		t.Fatalf("Expected no lost increments (500), got %d %v", counter, err)
+	}
+}
+
+func testCanceledContext(t *testing.T, h Harness, key func(string) string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := h.Storage.IncrementCounter(ctx, key("a")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := h.Storage.GetCounter(ctx, key("a")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if exists, _ := h.Storage.Exists(context.Background(), key("a")); exists {
+		t.Fatal("A canceled call should not change the storage")
+	}
+
+	// The storage stays usable for other callers
+	if counter, err := h.Storage.IncrementCounter(context.Background(), key("a")); err != nil || counter != 1 {
+		t.Fatalf("Expected counter 1, got %d %v", counter, err)
+	}
+}
+
+func testIncrementBy(t *testing.T, h Harness, key func(string) string) {
+	incrementer, ok := h.Storage.(strategy.BatchIncrementer)
+	if !ok {
+		t.Skip("storage does not implement BatchIncrementer")
+	}
+	ctx := context.Background()
+
+	if counter, err := incrementer.IncrementCounterBy(ctx, key("a"), 5); err != nil || counter != 5 {
+		t.Fatalf("Expected counter 5, got %d %v", counter, err)
+	}
+	if counter, err := incrementer.IncrementCounterBy(ctx, key("a"), -2); err != nil || counter != 3 {
+		t.Fatalf("Negative increments should refund, got %d %v", counter, err)
+	}
+}
+
+func testConsume(t *testing.T, h Harness, key func(string) string) {
+	consumer, ok := h.Storage.(strategy.AtomicConsumer)
+	if !ok {
+		t.Skip("storage does not implement AtomicConsumer")
+	}
+	ctx := context.Background()
+
+	counter, consumed, err := consumer.Consume(ctx, key("a"), 3, 5, 2)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("storage does not support Consume")
+	}
+	if err != nil || !consumed || counter != 3 {
+		t.Fatalf("Expected 3 units consumed, got %d %t %v", counter, consumed, err)
+	}
+	if counter, consumed, _ := consumer.Consume(ctx, key("a"), 3, 5, 2); consumed || counter != 3 {
+		t.Fatalf("Units over the limit should not be consumed, got %d %t", counter, consumed)
+	}
+	if counter, consumed, _ := consumer.Consume(ctx, key("a"), 2, 5, 2); !consumed || counter != 5 {
+		t.Fatalf("Units that fit should be consumed, got %d %t", counter, consumed)
+	}
+	if _, consumed, _ := consumer.Consume(ctx, key("a"), 0, 5, 2); consumed {
+		t.Fatal("Zero cost should be denied once the limit is reached")
+	}
+
+	// The window starts with the first consumption and is not extended
+	h.advance(2100 * time.Millisecond)
+	if counter, consumed, _ := consumer.Consume(ctx, key("a"), 1, 5, 2); !consumed || counter != 1 {
+		t.Fatalf("A new window should start after the TTL, got %d %t", counter, consumed)
+	}
+}
+
+func testLeases(t *testing.T, h Harness, key func(string) string) {
+	leases, ok := h.Storage.(strategy.LeaseStorage)
+	if !ok {
+		t.Skip("storage does not implement LeaseStorage")
+	}
+	ctx := context.Background()
+
+	for _, id := range []string{"first", "second"} {
+		if acquired, err := leases.AcquireLease(ctx, key("lease"), id, 2, time.Minute); errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("storage does not support leases")
+		} else if err != nil || !acquired {
+			t.Fatalf("Lease %s should be acquired, got %t %v", id, acquired, err)
+		}
+	}
+	if acquired, _ := leases.AcquireLease(ctx, key("lease"), "third", 2, time.Minute); acquired {
+		t.Fatal("Lease over the limit should not be acquired")
+	}
+
+	if err := leases.ReleaseLease(ctx, key("lease"), "first"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Renewing a released lease does not take the slot back
+	if err := leases.RenewLease(ctx, key("lease"), "first", time.Minute); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if acquired, _ := leases.AcquireLease(ctx, key("lease"), "third", 2, time.Minute); !acquired {
+		t.Fatal("Released slot should be available")
+	}
+}
+
+func testUsage(t *testing.T, h Harness, key func(string) string) {
+	usage, ok := h.Storage.(strategy.UsageStorage)
+	if !ok {
+		t.Skip("storage does not implement UsageStorage")
+	}
+	ctx := context.Background()
+	// Usage buckets are shared by every token, so a real Redis may hold live
+	// data: write an hour long gone, for a token no real client uses
+	hour := time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := key("acme")
+
+	for i := 0; i < 2; i++ {
+		err := usage.AddUsage(ctx, hour, map[string]strategy.UsageTotals{token: {Allowed: 2, Denied: 1, Cost: 5}}, 2*time.Second)
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("storage does not support usage accounting")
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	buckets, err := usage.Usage(ctx, hour, hour.Add(time.Hour))
+	if err != nil || len(buckets) != 1 || !buckets[0].Hour.Equal(hour) {
+		t.Fatalf("Expected one bucket, got %+v %v", buckets, err)
+	}
+	if totals := buckets[0].Tokens[token]; totals != (strategy.UsageTotals{Allowed: 4, Denied: 2, Cost: 10}) {
+		t.Fatalf("Totals should add up, got %+v", totals)
+	}
+
+	h.advance(2100 * time.Millisecond)
+	if buckets, _ := usage.Usage(ctx, hour, hour.Add(time.Hour)); len(buckets) != 0 {
+		t.Fatalf("Buckets should expire after the retention, got %+v", buckets)
+	}
+}
+
+func testClose(t *testing.T, h Harness, key func(string) string) {
+	ctx := context.Background()
+
+	if _, err := h.Storage.IncrementCounter(ctx, key("a")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := h.Storage.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := h.Storage.IncrementCounter(ctx, key("a")); err == nil {
+		t.Fatal("Calls after Close should fail")
+	}
+}
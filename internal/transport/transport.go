@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+)
+
+// KeyFunc returns the key outbound requests are counted under
+type KeyFunc func(r *http.Request) string
+
+// HostKey counts requests per destination host
+func HostKey(r *http.Request) string {
+	return r.URL.Host
+}
+
+// RoundTripper rate limits outbound requests with a policy of the limiter.
+// RoundTrip blocks until the policy allows the request or its context is done.
+// When an upstream answers with Retry-After, or with X-RateLimit-Remaining: 0
+// and X-RateLimit-Reset, the key is blocked in the storage for that long, so
+// every replica sharing the storage backs off together. Responses are returned
+// as they are, retrying is left to the caller.
+type RoundTripper struct {
+	next   http.RoundTripper
+	rl     *limiter.RateLimiter
+	policy string
+	key    KeyFunc
+}
+
+// New wraps next (http.DefaultTransport when nil). key defaults to HostKey.
+// The policy must be configured in the limiter.
+func New(next http.RoundTripper, rl *limiter.RateLimiter, policy string, key KeyFunc) (*RoundTripper, error) {
+	if _, exists := rl.Policy(policy); !exists {
+		return nil, fmt.Errorf("%w: %s", limiter.ErrUnknownPolicy, policy)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if key == nil {
+		key = HostKey
+	}
+	return &RoundTripper{next: next, rl: rl, policy: policy, key: key}, nil
+}
+
+func (t *RoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	keyParts := []string{t.key(r)}
+
+	if err := t.rl.Wait(ctx, t.policy, keyParts); err != nil {
+		// RoundTrip must close the body, even when the request is never sent
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if backoff := upstreamBackoff(resp, time.Now()); backoff > 0 {
+		if err := t.rl.Block(ctx, t.policy, keyParts, backoff); err != nil {
+			log.Printf("transport: failed to record upstream backoff for %s: %v", keyParts[0], err)
+		}
+	}
+	return resp, nil
+}
+
+// upstreamBackoff reports how long the upstream asked us to wait, zero when it did not
+func upstreamBackoff(resp *http.Response, now time.Time) time.Duration {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return date.Sub(now)
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset <= 0 {
+		return 0
+	}
+
+	// Some APIs send the reset as a Unix timestamp, others as seconds left
+	if reset > now.Unix()/2 {
+		return time.Unix(reset, 0).Sub(now)
+	}
+	return time.Duration(reset) * time.Second
+}
@@ -0,0 +1,178 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// newTransport wraps the default transport, failing the test on error
+func newTransport(t *testing.T, rl *limiter.RateLimiter, policy string, key KeyFunc) *RoundTripper {
+	t.Helper()
+	transport, err := New(nil, rl, policy, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return transport
+}
+
+// TestRoundTripperWaitsForWindow tests that requests above the limit wait for the next window
+func TestRoundTripperWaitsForWindow(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer upstream.Close()
+
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("partner", 1, 1)
+	client := &http.Client{Transport: newTransport(t, rl, "partner", nil)}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("Second request should wait for the window, took %v", elapsed)
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected 2 upstream calls, got %d", calls)
+	}
+}
+
+// TestRoundTripperRespectsContext tests that waiting stops when the context is done
+func TestRoundTripperRespectsContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("partner", 1, 60)
+	client := &http.Client{Transport: newTransport(t, rl, "partner", nil)}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", upstream.URL, nil)
+
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+// closeRecorder records whether the request body was closed
+type closeRecorder struct {
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Read(p []byte) (int, error) { return 0, io.EOF }
+
+func (c *closeRecorder) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// TestRoundTripperClosesBodyWhenWaitFails tests that the request body is
+// closed when the request is never sent
+func TestRoundTripperClosesBodyWhenWaitFails(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	rl.ConfigurePolicy("partner", 1, 60)
+	transport := newTransport(t, rl, "partner", func(r *http.Request) string { return "partner-api" })
+	rl.Check(context.Background(), "partner", []string{"partner-api"}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := &closeRecorder{}
+	req, _ := http.NewRequestWithContext(ctx, "POST", "http://partner.example", body)
+
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("Expected the wait to fail")
+	}
+	if !body.closed.Load() {
+		t.Fatal("Request body should be closed")
+	}
+}
+
+// TestNewRejectsUnknownPolicy tests that policies are validated when the transport is created
+func TestNewRejectsUnknownPolicy(t *testing.T) {
+	rl := limiter.NewRateLimiter(strategy.NewMemoryStorage(), 5, 300)
+	if _, err := New(nil, rl, "missing", nil); !errors.Is(err, limiter.ErrUnknownPolicy) {
+		t.Fatalf("Expected ErrUnknownPolicy, got %v", err)
+	}
+}
+
+// TestRoundTripperHonorsRetryAfter tests that upstream backoff blocks the key for every client
+func TestRoundTripperHonorsRetryAfter(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	storage := strategy.NewMemoryStorage()
+	rl := limiter.NewRateLimiter(storage, 5, 300)
+	rl.ConfigurePolicy("partner", 100, 60)
+	transport := newTransport(t, rl, "partner", func(r *http.Request) string { return "partner-api" })
+
+	resp, err := (&http.Client{Transport: transport}).Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	// A replica sharing the storage sees the block as well
+	replica := limiter.NewRateLimiter(storage, 5, 300)
+	replica.ConfigurePolicy("partner", 100, 60)
+	decision, err := replica.Check(context.Background(), "partner", []string{"partner-api"}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if decision.Allowed || decision.Reset < 29*time.Second {
+		t.Fatalf("Key should be blocked for the Retry-After, got %+v", decision)
+	}
+}
+
+// TestUpstreamBackoff tests parsing of the upstream rate limit headers
+func TestUpstreamBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	cases := []struct {
+		headers map[string]string
+		want    time.Duration
+	}{
+		{map[string]string{"Retry-After": "5"}, 5 * time.Second},
+		{map[string]string{"Retry-After": now.Add(10 * time.Second).UTC().Format(http.TimeFormat)}, 10 * time.Second},
+		{map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "20"}, 20 * time.Second},
+		{map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(now.Unix()+40, 10)}, 40 * time.Second},
+		{map[string]string{"X-RateLimit-Remaining": "3", "X-RateLimit-Reset": "20"}, 0},
+		{map[string]string{}, 0},
+	}
+
+	for _, c := range cases {
+		resp := &http.Response{Header: http.Header{}}
+		for name, value := range c.headers {
+			resp.Header.Set(name, value)
+		}
+		if got := upstreamBackoff(resp, now); got != c.want {
+			t.Fatalf("Headers %v: expected %v, got %v", c.headers, c.want, got)
+		}
+	}
+}
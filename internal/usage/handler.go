@@ -0,0 +1,137 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// maxRange bounds the time range of a single query
+const maxRange = 366 * 24 * time.Hour
+
+// Row is the usage of one token in one hour
+type Row struct {
+	Hour    time.Time `json:"hour"`
+	Token   string    `json:"token"`
+	Allowed int64     `json:"allowed"`
+	Denied  int64     `json:"denied"`
+	Cost    int64     `json:"cost"`
+}
+
+// Handler serves the usage totals for billing
+type Handler struct {
+	storage strategy.UsageStorage
+}
+
+func NewHandler(storage strategy.UsageStorage) *Handler {
+	return &Handler{storage: storage}
+}
+
+// Register mounts GET /usage on the mux. It reports the totals per token and
+// hour in [from, to) (RFC 3339, the last 24 hours by default), optionally for
+// a single token, as JSON, JSON Lines (format=jsonl) or CSV (format=csv).
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/usage", h.handleUsage)
+}
+
+func (h *Handler) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid to (expected RFC 3339)"})
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-24 * time.Hour)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid from (expected RFC 3339)"})
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) || to.Sub(from) > maxRange {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be before to, at most 366 days apart"})
+		return
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "jsonl" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be json, jsonl or csv"})
+		return
+	}
+
+	buckets, err := h.storage.Usage(r.Context(), from, to)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "storage unavailable"})
+		return
+	}
+	rows := toRows(buckets, query.Get("token"))
+
+	switch format {
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			encoder.Encode(row)
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"hour", "token", "allowed", "denied", "cost"})
+		for _, row := range rows {
+			writer.Write([]string{
+				row.Hour.Format(time.RFC3339),
+				row.Token,
+				strconv.FormatInt(row.Allowed, 10),
+				strconv.FormatInt(row.Denied, 10),
+				strconv.FormatInt(row.Cost, 10),
+			})
+		}
+		writer.Flush()
+	default:
+		writeJSON(w, http.StatusOK, rows)
+	}
+}
+
+// toRows flattens the buckets into rows sorted by hour and token, keeping
+// only the given token when it is set
+func toRows(buckets []strategy.UsageBucket, token string) []Row {
+	rows := []Row{}
+	for _, bucket := range buckets {
+		tokens := make([]string, 0, len(bucket.Tokens))
+		for name := range bucket.Tokens {
+			if token == "" || name == token {
+				tokens = append(tokens, name)
+			}
+		}
+		sort.Strings(tokens)
+
+		for _, name := range tokens {
+			totals := bucket.Tokens[name]
+			rows = append(rows, Row{Hour: bucket.Hour, Token: name, Allowed: totals.Allowed, Denied: totals.Denied, Cost: totals.Cost})
+		}
+	}
+	return rows
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// maxPending caps the token totals kept in memory, across hours, while the
+// storage cannot be written
+const maxPending = 100000
+
+// Recorder aggregates the decisions of token clients in memory and flushes
+// the totals per hour to the storage in the background, so recording adds no
+// storage call to the request path. IP clients are not recorded. While the
+// storage is down at most maxPending token totals are kept; decisions of
+// tokens that do not fit are dropped and counted.
+type Recorder struct {
+	storage    strategy.UsageStorage
+	retention  time.Duration
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[time.Time]map[string]strategy.UsageTotals
+	size    int
+	dropped int64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRecorder starts a recorder that flushes every interval. Hour buckets are
+// kept in the storage for retention.
+func NewRecorder(storage strategy.UsageStorage, interval time.Duration, retention time.Duration) *Recorder {
+	r := &Recorder{
+		storage:    storage,
+		retention:  retention,
+		maxPending: maxPending,
+		pending:    make(map[time.Time]map[string]strategy.UsageTotals),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go r.run(interval)
+	return r
+}
+
+// Observe records a limiter decision, see limiter.Observer
+func (r *Recorder) Observe(event limiter.Event) {
+	if event.Token == "" {
+		return
+	}
+	r.Record(event.Token, event.Decision.Allowed, event.Cost, event.Time)
+}
+
+// Record adds one request of the token to the hour bucket of at. Only allowed
+// requests count their cost, denied ones consume nothing.
+func (r *Recorder) Record(token string, allowed bool, cost int, at time.Time) {
+	hour := at.UTC().Truncate(time.Hour)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := r.pending[hour]
+	totals, exists := tokens[token]
+	if !exists {
+		if r.size >= r.maxPending {
+			r.dropped++
+			return
+		}
+		if tokens == nil {
+			tokens = make(map[string]strategy.UsageTotals)
+			r.pending[hour] = tokens
+		}
+		r.size++
+	}
+	if allowed {
+		totals.Allowed++
+		totals.Cost += int64(cost)
+	} else {
+		totals.Denied++
+	}
+	tokens[token] = totals
+}
+
+// Flush writes the pending totals to the storage. Totals that fail to be
+// written are kept and retried on the next flush.
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[time.Time]map[string]strategy.UsageTotals)
+	r.size = 0
+	r.mu.Unlock()
+
+	var firstErr error
+	for hour, tokens := range pending {
+		if err := r.storage.AddUsage(ctx, hour, tokens, r.retention); err != nil {
+			r.restore(hour, tokens)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// restore merges totals that could not be written back into the pending ones.
+// Totals that no longer fit are dropped and counted.
+func (r *Recorder) restore(hour time.Time, tokens map[string]strategy.UsageTotals) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.pending[hour]
+	if current == nil {
+		current = make(map[string]strategy.UsageTotals)
+		r.pending[hour] = current
+	}
+	for token, add := range tokens {
+		totals, exists := current[token]
+		if !exists {
+			if r.size >= r.maxPending {
+				r.dropped += add.Allowed + add.Denied
+				continue
+			}
+			r.size++
+		}
+		totals.Allowed += add.Allowed
+		totals.Denied += add.Denied
+		totals.Cost += add.Cost
+		current[token] = totals
+	}
+}
+
+func (r *Recorder) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := r.Flush(ctx); err != nil {
+				log.Printf("usage: failed to flush totals, will retry: %v", err)
+			}
+			cancel()
+			if dropped := r.takeDropped(); dropped > 0 {
+				log.Printf("usage: dropped %d decisions, %d token totals were already pending", dropped, r.maxPending)
+			}
+		}
+	}
+}
+
+// takeDropped returns the dropped decisions and resets the count
+func (r *Recorder) takeDropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := r.dropped
+	r.dropped = 0
+	return dropped
+}
+
+// Close stops the background flush and writes the remaining totals. It is
+// safe to call more than once.
+func (r *Recorder) Close(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+	return r.Flush(ctx)
+}
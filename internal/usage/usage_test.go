@@ -0,0 +1,174 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// failingUsage fails every write until it is healed
+type failingUsage struct {
+	*strategy.MemoryStorage
+	failing bool
+}
+
+func (f *failingUsage) AddUsage(ctx context.Context, hour time.Time, totals map[string]strategy.UsageTotals, retention time.Duration) error {
+	if f.failing {
+		return errors.New("connection refused")
+	}
+	return f.MemoryStorage.AddUsage(ctx, hour, totals, retention)
+}
+
+// TestRecorderAggregatesLimiterDecisions tests that token decisions are
+// totaled per hour and written on flush
+func TestRecorderAggregatesLimiterDecisions(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	recorder := NewRecorder(storage, time.Hour, 24*time.Hour)
+	defer recorder.Close(context.Background())
+
+	rl := limiter.NewRateLimiter(storage, 3, 60)
+	rl.AddObserver(recorder)
+	ctx := context.Background()
+
+	rl.AllowN(ctx, "10.0.0.1", "acme", 2)
+	rl.Allow(ctx, "10.0.0.1", "acme")
+	rl.Allow(ctx, "10.0.0.1", "acme")
+	rl.Allow(ctx, "10.0.0.2", "")
+
+	now := time.Now()
+	if buckets, _ := storage.Usage(ctx, now.Add(-time.Hour), now.Add(time.Hour)); len(buckets) != 0 {
+		t.Fatal("Totals should only be written on flush")
+	}
+
+	if err := recorder.Flush(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buckets, _ := storage.Usage(ctx, now.Add(-time.Hour), now.Add(time.Hour))
+	if len(buckets) != 1 || !buckets[0].Hour.Equal(now.UTC().Truncate(time.Hour)) {
+		t.Fatalf("Expected the current hour bucket, got %+v", buckets)
+	}
+	if totals := buckets[0].Tokens; len(totals) != 1 || totals["acme"] != (strategy.UsageTotals{Allowed: 2, Denied: 1, Cost: 3}) {
+		t.Fatalf("Unexpected totals %+v", totals)
+	}
+}
+
+// TestRecorderRetriesFailedFlush tests that totals are kept when the storage fails
+func TestRecorderRetriesFailedFlush(t *testing.T) {
+	storage := &failingUsage{MemoryStorage: strategy.NewMemoryStorage(), failing: true}
+	recorder := NewRecorder(storage, time.Hour, 24*time.Hour)
+	ctx := context.Background()
+	at := time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)
+
+	recorder.Record("acme", true, 5, at)
+	if err := recorder.Flush(ctx); err == nil {
+		t.Fatal("Expected flush error")
+	}
+	recorder.Record("acme", true, 1, at)
+
+	storage.failing = false
+	if err := recorder.Close(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buckets, _ := storage.Usage(ctx, at, at.Add(time.Hour))
+	if len(buckets) != 1 || buckets[0].Tokens["acme"] != (strategy.UsageTotals{Allowed: 2, Cost: 6}) {
+		t.Fatalf("Failed totals should be retried, got %+v", buckets)
+	}
+}
+
+// TestRecorderCloseTwice tests that closing the recorder again does not panic
+func TestRecorderCloseTwice(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	recorder := NewRecorder(storage, time.Hour, 24*time.Hour)
+	ctx := context.Background()
+	at := time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)
+
+	recorder.Record("acme", true, 1, at)
+	for i := 0; i < 2; i++ {
+		if err := recorder.Close(ctx); err != nil {
+			t.Fatalf("Close %d: unexpected error: %v", i+1, err)
+		}
+	}
+	buckets, _ := storage.Usage(ctx, at, at.Add(time.Hour))
+	if len(buckets) != 1 || buckets[0].Tokens["acme"] != (strategy.UsageTotals{Allowed: 1, Cost: 1}) {
+		t.Fatalf("Totals should be written once, got %+v", buckets)
+	}
+}
+
+// TestRecorderCapsPendingTotals tests that pending totals stop growing while the storage is down
+func TestRecorderCapsPendingTotals(t *testing.T) {
+	storage := &failingUsage{MemoryStorage: strategy.NewMemoryStorage(), failing: true}
+	recorder := NewRecorder(storage, time.Hour, 24*time.Hour)
+	recorder.maxPending = 2
+	ctx := context.Background()
+	at := time.Date(2026, 10, 19, 14, 30, 0, 0, time.UTC)
+
+	recorder.Record("acme", true, 1, at)
+	recorder.Record("globex", true, 1, at)
+	recorder.Flush(ctx)
+
+	// Known tokens keep aggregating, new ones are dropped
+	recorder.Record("acme", false, 1, at)
+	recorder.Record("initech", true, 1, at)
+	recorder.Record("umbrella", true, 1, at.Add(time.Hour))
+	if recorder.size != 2 || recorder.dropped != 2 {
+		t.Fatalf("Expected 2 pending totals and 2 dropped decisions, got %d and %d", recorder.size, recorder.dropped)
+	}
+
+	storage.failing = false
+	if err := recorder.Close(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buckets, _ := storage.Usage(ctx, at, at.Add(2*time.Hour))
+	if len(buckets) != 1 || buckets[0].Tokens["acme"] != (strategy.UsageTotals{Allowed: 1, Denied: 1, Cost: 1}) {
+		t.Fatalf("Unexpected buckets %+v", buckets)
+	}
+}
+
+// TestHandlerExport tests the JSON, JSON Lines and CSV exports
+func TestHandlerExport(t *testing.T) {
+	storage := strategy.NewMemoryStorage()
+	ctx := context.Background()
+	first := time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC)
+	storage.AddUsage(ctx, first, map[string]strategy.UsageTotals{"acme": {Allowed: 10, Cost: 12}, "globex": {Denied: 2}}, time.Hour)
+	storage.AddUsage(ctx, first.Add(time.Hour), map[string]strategy.UsageTotals{"acme": {Allowed: 1, Cost: 1}}, time.Hour)
+
+	mux := http.NewServeMux()
+	NewHandler(storage).Register(mux)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/usage?"+query, nil))
+		return w
+	}
+
+	w := get("from=2026-10-19T13:00:00Z&to=2026-10-19T15:00:00Z")
+	var rows []Row
+	json.NewDecoder(w.Body).Decode(&rows)
+	if w.Code != http.StatusOK || len(rows) != 3 || rows[0].Token != "acme" || rows[1].Token != "globex" || rows[2].Hour != first.Add(time.Hour) {
+		t.Fatalf("Unexpected rows %+v", rows)
+	}
+
+	w = get("from=2026-10-19T13:00:00Z&to=2026-10-19T15:00:00Z&token=acme&format=jsonl")
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 2 {
+		t.Fatalf("Expected one JSON line per hour of the token, got %q", w.Body.String())
+	}
+
+	w = get("from=2026-10-19T13:00:00Z&to=2026-10-19T14:00:00Z&format=csv")
+	expected := "hour,token,allowed,denied,cost\n2026-10-19T13:00:00Z,acme,10,0,12\n2026-10-19T13:00:00Z,globex,0,2,0\n"
+	if w.Body.String() != expected {
+		t.Fatalf("Unexpected CSV %q", w.Body.String())
+	}
+
+	for _, invalid := range []string{"from=yesterday", "from=2026-10-19T15:00:00Z&to=2026-10-19T13:00:00Z", "format=xml", "from=2020-01-01T00:00:00Z&to=2026-01-01T00:00:00Z"} {
+		if w := get(invalid); w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected 400 for %q, got %d", invalid, w.Code)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+const (
+	// EventQuotaThreshold is sent when a token uses a share of a quota window
+	EventQuotaThreshold = "quota.threshold"
+	// EventTokenBlocked is sent when a token starts being denied
+	EventTokenBlocked = "token.blocked"
+)
+
+const (
+	queueSize   = 1000
+	workers     = 4
+	maxAttempts = 5
+	maxDelay    = time.Minute
+	// blockedTTL bounds the deduplication of a block whose end is unknown
+	blockedTTL = time.Minute
+)
+
+// Threshold fires a notification when a token uses Percent of its quota
+// window of Period, or when it is blocked
+type Threshold struct {
+	Period  limiter.Period
+	Percent int
+	Blocked bool
+}
+
+// Payload is the JSON body of a notification
+type Payload struct {
+	// ID is the same for every delivery of a notification, receivers can use it to deduplicate
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	Token     string    `json:"token"`
+	Period    string    `json:"period,omitempty"`
+	Threshold int       `json:"threshold,omitempty"`
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	ResetsAt  time.Time `json:"resets_at"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// notification is a payload waiting to be claimed and delivered
+type notification struct {
+	key     string
+	payload Payload
+	// ttl is how long the notification stays claimed; zero means until the
+	// counter key expires
+	ttl        time.Duration
+	counterKey string
+}
+
+// Notifier sends signed webhooks when tokens cross the thresholds. It observes
+// the limiter decisions without blocking them: notifications are queued and
+// delivered in the background, with retries and exponential backoff. Each
+// threshold fires once per window across replicas sharing the storage.
+type Notifier struct {
+	storage    strategy.StorageStrategy
+	url        string
+	secret     []byte
+	thresholds []Threshold
+	client     *http.Client
+	baseDelay  time.Duration
+
+	mu     sync.Mutex
+	fired  map[string]time.Time
+	closed bool
+
+	queue     chan notification
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	// ctx is canceled when Close gives up waiting, aborting pending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewNotifier starts a notifier posting to url, signing each body with secret
+func NewNotifier(storage strategy.StorageStrategy, url string, secret string, thresholds []Threshold) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		storage:    storage,
+		url:        url,
+		secret:     []byte(secret),
+		thresholds: thresholds,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		baseDelay:  time.Second,
+		fired:      make(map[string]time.Time),
+		queue:      make(chan notification, queueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for i := 0; i < workers; i++ {
+		n.wg.Add(1)
+		go n.run()
+	}
+	return n
+}
+
+// Observe queues the notifications for the thresholds crossed by a decision, see limiter.Observer
+func (n *Notifier) Observe(event limiter.Event) {
+	if event.Token == "" {
+		return
+	}
+
+	for _, threshold := range n.thresholds {
+		if threshold.Blocked {
+			if !event.Decision.Allowed {
+				n.enqueue(blockedNotification(event))
+			}
+			continue
+		}
+
+		for _, quota := range event.Quotas {
+			if quota.Period == threshold.Period && quota.Used*100 >= threshold.Percent*quota.Limit {
+				n.enqueue(thresholdNotification(event, threshold, quota))
+			}
+		}
+	}
+}
+
+func thresholdNotification(event limiter.Event, threshold Threshold, quota limiter.QuotaUsage) notification {
+	key := fmt.Sprintf("limiter:webhook:%d:%s:%d:{token:%s}", threshold.Percent, quota.Period, quota.ResetsAt.Unix(), event.Token)
+	return notification{
+		key: key,
+		ttl: quota.ResetsAt.Sub(event.Time),
+		payload: Payload{
+			Event:     EventQuotaThreshold,
+			Token:     event.Token,
+			Period:    string(quota.Period),
+			Threshold: threshold.Percent,
+			Limit:     quota.Limit,
+			Used:      quota.Used,
+			ResetsAt:  quota.ResetsAt,
+		},
+	}
+}
+
+// blockedNotification fires once per denying quota window, or once per block
+// of the counter key for tokens without quotas
+func blockedNotification(event limiter.Event) notification {
+	payload := Payload{
+		Event: EventTokenBlocked,
+		Token: event.Token,
+		Limit: event.Decision.Limit,
+		Used:  event.Decision.Limit - event.Decision.Remaining,
+	}
+
+	if len(event.Quotas) > 0 {
+		quota := event.Quotas[len(event.Quotas)-1]
+		payload.Period = string(quota.Period)
+		payload.Used = quota.Used
+		payload.ResetsAt = quota.ResetsAt
+		key := fmt.Sprintf("limiter:webhook:blocked:%s:%d:{token:%s}", quota.Period, quota.ResetsAt.Unix(), event.Token)
+		return notification{key: key, ttl: quota.ResetsAt.Sub(event.Time), payload: payload}
+	}
+
+	key := "limiter:webhook:blocked:" + strings.TrimPrefix(event.Key, "limiter:")
+	if event.Decision.Reset > 0 {
+		payload.ResetsAt = event.Time.Add(event.Decision.Reset)
+		return notification{key: key, ttl: event.Decision.Reset, payload: payload}
+	}
+	return notification{key: key, counterKey: event.Key, payload: payload}
+}
+
+// enqueue queues the notification unless it was already fired by this instance
+func (n *Notifier) enqueue(notification notification) {
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if expiresAt, exists := n.fired[notification.key]; n.closed || (exists && now.Before(expiresAt)) {
+		return
+	}
+	ttl := notification.ttl
+	if ttl <= 0 {
+		ttl = blockedTTL
+	}
+	n.fired[notification.key] = now.Add(ttl)
+
+	notification.payload.Timestamp = now
+	select {
+	case n.queue <- notification:
+	default:
+		log.Printf("webhook: queue full, dropping %s for %s", notification.payload.Event, notification.payload.Token)
+	}
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+
+	for notification := range n.queue {
+		n.prune()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		claimed, err := n.claim(ctx, &notification)
+		cancel()
+		if err != nil {
+			// Delivering twice is better than not delivering at all
+			log.Printf("webhook: could not deduplicate %s, delivering anyway: %v", notification.key, err)
+		} else if !claimed {
+			continue
+		}
+
+		if err := n.deliver(n.ctx, notification.payload); err != nil {
+			log.Printf("webhook: giving up on %s, it fires again on the next decision: %v", notification.payload.ID, err)
+			n.release(notification, claimed)
+		}
+	}
+}
+
+// release forgets a notification that could not be delivered, locally and in
+// the shared storage, so the next decision crossing the threshold fires it again
+func (n *Notifier) release(notification notification, claimed bool) {
+	n.mu.Lock()
+	delete(n.fired, notification.key)
+	n.mu.Unlock()
+
+	if !claimed {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.storage.Delete(ctx, notification.key); err != nil {
+		log.Printf("webhook: failed to release %s, it will not fire again this window: %v", notification.key, err)
+	}
+}
+
+// claim marks the notification as fired in the shared storage, so only one
+// replica delivers it within its window
+func (n *Notifier) claim(ctx context.Context, notification *notification) (bool, error) {
+	if notification.ttl <= 0 {
+		notification.ttl = blockedTTL
+		if reader, ok := n.storage.(strategy.TTLReader); ok && notification.counterKey != "" {
+			if ttl, err := reader.TTL(ctx, notification.counterKey); err == nil && ttl > 0 {
+				notification.ttl = ttl
+			}
+		}
+		notification.payload.ResetsAt = notification.payload.Timestamp.Add(notification.ttl)
+	}
+	notification.payload.ID = notificationID(notification.key, notification.payload.ResetsAt)
+
+	seconds := max(int(math.Ceil(notification.ttl.Seconds())), 1)
+	if consumer, ok := n.storage.(strategy.AtomicConsumer); ok {
+		_, claimed, err := consumer.Consume(ctx, notification.key, 1, 1, seconds)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return claimed, err
+		}
+	}
+
+	counter, err := n.storage.IncrementCounter(ctx, notification.key)
+	if err != nil || counter != 1 {
+		return false, err
+	}
+	return true, n.storage.SetExpiration(ctx, notification.key, seconds)
+}
+
+// deliver posts the payload, retrying with exponential backoff on network
+// errors, 429 and 5xx responses. It returns the last error once the attempts
+// are exhausted or ctx is done.
+func (n *Notifier) deliver(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		// Retrying cannot fix the payload
+		log.Printf("webhook: failed to encode %s: %v", payload.ID, err)
+		return nil
+	}
+
+	delay := n.baseDelay
+	for attempt := 1; ; attempt++ {
+		err := n.post(ctx, payload.ID, body)
+		if err == nil {
+			return nil
+		}
+		if attempt == maxAttempts {
+			return fmt.Errorf("%d attempts failed: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w after %d attempts: %w", ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxDelay)
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, id string, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", id)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(n.secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+	default:
+		// The receiver rejected the notification, retrying will not help
+		log.Printf("webhook: %s rejected with status %d", id, resp.StatusCode)
+		return nil
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "timestamp.body", the value of the
+// X-Webhook-Signature header after "sha256="
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notificationID identifies a notification by its key and the end of its
+// window, so a token blocked again later gets a new id
+func notificationID(key string, resetsAt time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", key, resetsAt.Unix())))
+	return hex.EncodeToString(sum[:16])
+}
+
+// prune drops expired entries from the local fired set
+func (n *Notifier) prune() {
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for key, expiresAt := range n.fired {
+		if !now.Before(expiresAt) {
+			delete(n.fired, key)
+		}
+	}
+}
+
+// Close stops accepting notifications and waits for the queued ones to be
+// delivered, or for ctx to be done, in which case pending retries are aborted.
+// It is safe to call more than once.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.closeOnce.Do(func() {
+		n.mu.Lock()
+		n.closed = true
+		close(n.queue)
+		n.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		n.cancel()
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		return ctx.Err()
+	}
+}
+
+// ParseThresholds parses a comma separated list of period:percent entries
+// and "blocked", e.g. "day:80,day:100,month:90,blocked"
+func ParseThresholds(value string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "blocked" {
+			thresholds = append(thresholds, Threshold{Blocked: true})
+			continue
+		}
+
+		period, percent, found := strings.Cut(entry, ":")
+		parsed, err := strconv.Atoi(percent)
+		if !found || !validPeriod(limiter.Period(period)) || err != nil || parsed <= 0 || parsed > 100 {
+			return nil, fmt.Errorf("invalid webhook threshold %q (expected period:percent or blocked)", entry)
+		}
+		thresholds = append(thresholds, Threshold{Period: limiter.Period(period), Percent: parsed})
+	}
+	return thresholds, nil
+}
+
+func validPeriod(period limiter.Period) bool {
+	switch period {
+	case limiter.PeriodSecond, limiter.PeriodMinute, limiter.PeriodHour, limiter.PeriodDay, limiter.PeriodMonth:
+		return true
+	}
+	return false
+}
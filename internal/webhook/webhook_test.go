@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+)
+
+// receiver records the webhooks it gets, failing the first failures calls
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	payloads []Payload
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	rv.calls++
+	if rv.calls <= rv.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get("X-Webhook-Signature") != "sha256="+Sign([]byte("secret"), r.Header.Get("X-Webhook-Timestamp"), body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var payload Payload
+	json.Unmarshal(body, &payload)
+	rv.payloads = append(rv.payloads, payload)
+}
+
+func (rv *receiver) received() []Payload {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	return append([]Payload(nil), rv.payloads...)
+}
+
+// TestThresholdsFireOncePerWindowAcrossReplicas tests that each threshold is
+// delivered once even when several replicas observe it
+func TestThresholdsFireOncePerWindowAcrossReplicas(t *testing.T) {
+	rv := &receiver{}
+	server := httptest.NewServer(rv)
+	defer server.Close()
+
+	storage := strategy.NewMemoryStorage()
+	thresholds, _ := ParseThresholds("day:80,day:100,blocked")
+
+	var notifiers []*Notifier
+	for i := 0; i < 2; i++ {
+		rl := limiter.NewRateLimiter(storage, 5, 300)
+		rl.ConfigureQuotas("acme", limiter.QuotaWindow{Period: limiter.PeriodDay, Limit: 5})
+		notifier := NewNotifier(storage, server.URL, "secret", thresholds)
+		rl.AddObserver(notifier)
+		notifiers = append(notifiers, notifier)
+
+		// Each replica serves requests of the same token
+		for j := 0; j < 4; j++ {
+			rl.Allow(context.Background(), "10.0.0.1", "acme")
+		}
+	}
+
+	for _, notifier := range notifiers {
+		if err := notifier.Close(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, payload := range rv.received() {
+		counts[fmt.Sprintf("%s:%s:%d", payload.Event, payload.Period, payload.Threshold)]++
+		if payload.Token != "acme" || payload.Limit != 5 || payload.ID == "" {
+			t.Fatalf("Unexpected payload %+v", payload)
+		}
+	}
+	if len(rv.received()) != 3 || counts["quota.threshold:day:80"] != 1 || counts["quota.threshold:day:100"] != 1 || counts["token.blocked:day:0"] != 1 {
+		t.Fatalf("Expected 80%%, 100%% and blocked once each, got %+v", rv.received())
+	}
+}
+
+// TestDeliveryRetries tests that failed deliveries are retried with backoff
+func TestDeliveryRetries(t *testing.T) {
+	rv := &receiver{failures: 2}
+	server := httptest.NewServer(rv)
+	defer server.Close()
+
+	storage := strategy.NewMemoryStorage()
+	rl := limiter.NewRateLimiter(storage, 1, 60)
+	notifier := NewNotifier(storage, server.URL, "secret", []Threshold{{Blocked: true}})
+	notifier.baseDelay = time.Millisecond
+	rl.AddObserver(notifier)
+
+	rl.Allow(context.Background(), "10.0.0.1", "acme")
+	rl.Allow(context.Background(), "10.0.0.1", "acme")
+	rl.Allow(context.Background(), "10.0.0.1", "acme")
+	notifier.Close(context.Background())
+
+	payloads := rv.received()
+	if len(payloads) != 1 || rv.calls != 3 {
+		t.Fatalf("Expected one delivery after two failures, got %d calls and %+v", rv.calls, payloads)
+	}
+	if payloads[0].Event != EventTokenBlocked || payloads[0].Period != "" || payloads[0].ResetsAt.Before(time.Now().Add(50*time.Second)) {
+		t.Fatalf("Block should be reported until the window resets, got %+v", payloads[0])
+	}
+}
+
+// TestUndeliveredNotificationFiresAgain tests that a notification out of retries is released
+func TestUndeliveredNotificationFiresAgain(t *testing.T) {
+	rv := &receiver{failures: maxAttempts}
+	server := httptest.NewServer(rv)
+	defer server.Close()
+
+	storage := strategy.NewMemoryStorage()
+	rl := limiter.NewRateLimiter(storage, 1, 60)
+	notifier := NewNotifier(storage, server.URL, "secret", []Threshold{{Blocked: true}})
+	notifier.baseDelay = time.Millisecond
+	rl.AddObserver(notifier)
+	ctx := context.Background()
+
+	rl.Allow(ctx, "10.0.0.1", "acme")
+	rl.Allow(ctx, "10.0.0.1", "acme")
+
+	// Wait until the delivery gives up and the claim is released
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		notifier.mu.Lock()
+		released := len(notifier.fired) == 0
+		notifier.mu.Unlock()
+		if released {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Undelivered notification was not released")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rl.Allow(ctx, "10.0.0.1", "acme")
+	notifier.Close(ctx)
+
+	if payloads := rv.received(); len(payloads) != 1 || payloads[0].Event != EventTokenBlocked {
+		t.Fatalf("Expected the block to be delivered on the next decision, got %+v", payloads)
+	}
+}
+
+// TestCloseAbortsBackoff tests that Close does not wait for retries past its
+// context and can be called again
+func TestCloseAbortsBackoff(t *testing.T) {
+	rv := &receiver{failures: maxAttempts}
+	server := httptest.NewServer(rv)
+	defer server.Close()
+
+	storage := strategy.NewMemoryStorage()
+	rl := limiter.NewRateLimiter(storage, 1, 60)
+	notifier := NewNotifier(storage, server.URL, "secret", []Threshold{{Blocked: true}})
+	notifier.baseDelay = time.Hour
+	rl.AddObserver(notifier)
+
+	rl.Allow(context.Background(), "10.0.0.1", "acme")
+	rl.Allow(context.Background(), "10.0.0.1", "acme")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := notifier.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the context error, got %v", err)
+	}
+
+	// The aborted worker exits, so a second Close returns at once
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		t.Fatalf("Unexpected error on second Close: %v", err)
+	}
+}
+
+// TestParseThresholds tests parsing of the threshold configuration
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("day:80, month:100,blocked")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thresholds) != 3 || thresholds[0] != (Threshold{Period: limiter.PeriodDay, Percent: 80}) || !thresholds[2].Blocked {
+		t.Fatalf("Unexpected thresholds %+v", thresholds)
+	}
+
+	for _, invalid := range []string{"day", "week:80", "day:0", "day:101", "day:x"} {
+		if _, err := ParseThresholds(invalid); err == nil {
+			t.Fatalf("Expected error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package grpc applies the rate limiter to gRPC servers. It lives apart from
+// package ratelimit so HTTP-only services do not depend on gRPC.
+package grpc
+
+import (
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/interceptor"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit"
+)
+
+// Interceptor applies the limiter to gRPC calls, use its Unary and Stream
+// methods with grpc.UnaryInterceptor and grpc.StreamInterceptor. Clients are
+// identified by the api_key or authorization metadata, then the peer address.
+// Denied calls get RESOURCE_EXHAUSTED with RetryInfo in the status details;
+// streams are checked when they open.
+type Interceptor = interceptor.Interceptor
+
+// NewInterceptor creates the interceptor. methodPolicies maps
+// "/pkg.Service/Method" or "/pkg.Service/*" to a policy; other methods use the
+// IP/token limits. It fails with ratelimit.ErrUnknownPolicy when a method
+// references a policy the limiter does not have.
+func NewInterceptor(rl *ratelimit.Limiter, methodPolicies map[string]string) (*Interceptor, error) {
+	return interceptor.New(rl, methodPolicies)
+}
@@ -0,0 +1,31 @@
+package grpc_test
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit"
+	ratelimitgrpc "github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit/grpc"
+)
+
+// Compile-time guards for the exported surface, see package ratelimit
+var (
+	_ func(*ratelimit.Limiter, map[string]string) (*ratelimitgrpc.Interceptor, error) = ratelimitgrpc.NewInterceptor
+	_ func(*ratelimitgrpc.Interceptor) grpc.UnaryServerInterceptor                    = (*ratelimitgrpc.Interceptor).Unary
+	_ func(*ratelimitgrpc.Interceptor) grpc.StreamServerInterceptor                   = (*ratelimitgrpc.Interceptor).Stream
+)
+
+// TestNewInterceptor tests that method policies must be configured in the limiter
+func TestNewInterceptor(t *testing.T) {
+	rl := ratelimit.New(ratelimit.NewMemoryStorage(), ratelimit.WithPolicy("export", 5, 60))
+	defer rl.Close()
+
+	if _, err := ratelimitgrpc.NewInterceptor(rl, map[string]string{"/orders.v1.Orders/Export": "export"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := ratelimitgrpc.NewInterceptor(rl, map[string]string{"/search.v1.Search/*": "search"}); !errors.Is(err, ratelimit.ErrUnknownPolicy) {
+		t.Fatalf("Expected ErrUnknownPolicy, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import "time"
+
+type tokenSettings struct {
+	token         string
+	limit         int
+	blockDuration int
+}
+
+type quotaSettings struct {
+	token   string
+	windows []QuotaWindow
+}
+
+type settings struct {
+	limit         int
+	blockDuration int
+	tokens        []tokenSettings
+	policies      []Policy
+	quotas        []quotaSettings
+	quotaLocation *time.Location
+	failureMode   FailureMode
+	fallback      Storage
+	timeout       time.Duration
+	clock         Clock
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		limit:         5,
+		blockDuration: 300,
+		failureMode:   FailClosed,
+	}
+}
+
+// Option configures a Limiter created by New
+type Option func(*settings)
+
+// WithLimit sets the default number of requests allowed per window
+func WithLimit(limit int) Option {
+	return func(s *settings) {
+		s.limit = limit
+	}
+}
+
+// WithBlockDuration sets the default window and block duration in seconds
+func WithBlockDuration(seconds int) Option {
+	return func(s *settings) {
+		s.blockDuration = seconds
+	}
+}
+
+// WithToken sets a custom limit and block duration for a token
+func WithToken(token string, limit int, blockDuration int) Option {
+	return func(s *settings) {
+		s.tokens = append(s.tokens, tokenSettings{token: token, limit: limit, blockDuration: blockDuration})
+	}
+}
+
+// WithFailureMode sets how requests are decided when the storage is unavailable.
+// FailLocal uses an in-memory fallback unless WithFallbackStorage is given.
+func WithFailureMode(mode FailureMode) Option {
+	return func(s *settings) {
+		s.failureMode = mode
+		if mode == FailLocal && s.fallback == nil {
+			s.fallback = NewMemoryStorage()
+		}
+	}
+}
+
+// WithDecisionTimeout bounds the time each decision may spend on the storage,
+// e.g. 20ms. Decisions that run out of time follow the failure mode.
+func WithDecisionTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.timeout = timeout
+	}
+}
+
+// WithClock sets the clock used for quota windows, reservations and observer
+// events, e.g. a FakeClock in tests. Give the same clock to
+// NewMemoryStorageWithClock so counters expire with it.
+func WithClock(clock Clock) Option {
+	return func(s *settings) {
+		s.clock = clock
+	}
+}
+
+// WithFallbackStorage sets the storage used with FailLocal
+func WithFallbackStorage(storage Storage) Option {
+	return func(s *settings) {
+		s.fallback = storage
+	}
+}
+
+// WithPolicy adds a named policy for Limiter.Check
+func WithPolicy(name string, limit int, blockDuration int) Option {
+	return func(s *settings) {
+		s.policies = append(s.policies, Policy{Name: name, Limit: limit, BlockDuration: blockDuration})
+	}
+}
+
+// WithShadowPolicy sets a named policy that is evaluated and counted but
+// never denies, to see what a new limit would do before enforcing it
+func WithShadowPolicy(name string, limit int, blockDuration int) Option {
+	return func(s *settings) {
+		s.policies = append(s.policies, Policy{Name: name, Limit: limit, BlockDuration: blockDuration, Shadow: true})
+	}
+}
+
+// WithQuotas sets stacked quota windows for a token, all enforced on each
+// request, e.g. 10 per second and 1,000,000 per month. Windows with an unknown
+// period or a negative limit make NewE return an error; New logs it and
+// leaves the token without quotas.
+func WithQuotas(token string, windows ...QuotaWindow) Option {
+	return func(s *settings) {
+		s.quotas = append(s.quotas, quotaSettings{token: token, windows: windows})
+	}
+}
+
+// WithQuotaLocation sets the time zone quota windows are aligned to (UTC by default)
+func WithQuotaLocation(loc *time.Location) Option {
+	return func(s *settings) {
+		s.quotaLocation = loc
+	}
+}
@@ -0,0 +1,397 @@
+// Package ratelimit is the public API of the rate limiter. It exposes the
+// limiter, the storage backends and the HTTP middleware so other services can
+// import them instead of copying the code under internal/.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/clock"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/limiter"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/middleware"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/transport"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/usage"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/webhook"
+)
+
+// Limiter decides whether requests are allowed by IP or token
+type Limiter = limiter.RateLimiter
+
+// Storage is the interface implemented by every storage backend
+type Storage = strategy.StorageStrategy
+
+// FailureMode defines how the limiter decides when the storage is unavailable
+type FailureMode = limiter.FailureMode
+
+const (
+	FailClosed = limiter.FailClosed
+	FailOpen   = limiter.FailOpen
+	FailLocal  = limiter.FailLocal
+)
+
+// ErrStorageUnavailable is returned when a decision cannot be made because the storage failed
+var ErrStorageUnavailable = limiter.ErrStorageUnavailable
+
+// ErrStorageTimeout is returned along with ErrStorageUnavailable when the
+// storage did not answer in time
+var ErrStorageTimeout = limiter.ErrStorageTimeout
+
+// StorageFailures counts the decisions the storage failed to answer, timeouts apart
+type StorageFailures = limiter.StorageFailures
+
+// ErrUnknownPolicy is returned when a check references a policy that is not configured
+var ErrUnknownPolicy = limiter.ErrUnknownPolicy
+
+// ErrExceedsLimit is returned when a reservation asks for more units than the policy allows per window
+var ErrExceedsLimit = limiter.ErrExceedsLimit
+
+// ErrReservationCanceled is returned when waiting on a canceled reservation
+var ErrReservationCanceled = limiter.ErrReservationCanceled
+
+// Reservation holds units of a policy, see Limiter.Reserve
+type Reservation = limiter.Reservation
+
+// Policy is a named limit applied to arbitrary keys with Limiter.Check
+type Policy = limiter.Policy
+
+// Decision is the outcome of Limiter.Check
+type Decision = limiter.Decision
+
+// DefaultPolicy always exists and uses the default limit
+const DefaultPolicy = limiter.DefaultPolicy
+
+// ParsePolicies parses a comma separated list of name=limit/seconds entries,
+// with a "/shadow" suffix for shadow policies
+func ParsePolicies(value string) ([]Policy, error) {
+	return limiter.ParsePolicies(value)
+}
+
+// ShadowStats counts the outcomes of a shadow policy, see Limiter.ShadowStats
+type ShadowStats = limiter.ShadowStats
+
+// QuotaWindow allows a number of units per calendar period, see WithQuotas
+type QuotaWindow = limiter.QuotaWindow
+
+// QuotaUsage is the state of a token quota window, see Limiter.QuotaUsage
+type QuotaUsage = limiter.QuotaUsage
+
+// Period is the length of a quota window
+type Period = limiter.Period
+
+const (
+	PeriodSecond = limiter.PeriodSecond
+	PeriodMinute = limiter.PeriodMinute
+	PeriodHour   = limiter.PeriodHour
+	PeriodDay    = limiter.PeriodDay
+	PeriodMonth  = limiter.PeriodMonth
+)
+
+// ParseQuotas parses a comma separated list of token=limit/period;... entries
+func ParseQuotas(value string) (map[string][]QuotaWindow, error) {
+	return limiter.ParseQuotas(value)
+}
+
+// Event is a client decision reported to observers, see Limiter.AddObserver
+type Event = limiter.Event
+
+// Observer is notified of every client decision, it must not block
+type Observer = limiter.Observer
+
+// UsageRecorder aggregates token usage per hour for billing, see NewUsageRecorder
+type UsageRecorder = usage.Recorder
+
+// NewUsageRecorder returns an observer that totals the requests allowed,
+// denied and the cost units of each token per hour, flushing them to the
+// storage every interval and keeping them for retention. Register it with
+// Limiter.AddObserver and Close it on shutdown.
+func NewUsageRecorder(storage Storage, interval time.Duration, retention time.Duration) (*UsageRecorder, error) {
+	usageStorage, ok := storage.(strategy.UsageStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage does not support usage accounting: %w", errors.ErrUnsupported)
+	}
+	return usage.NewRecorder(usageStorage, interval, retention), nil
+}
+
+// WebhookThreshold fires a notification at a share of a quota window or when a token is blocked
+type WebhookThreshold = webhook.Threshold
+
+// WebhookPayload is the JSON body of a notification
+type WebhookPayload = webhook.Payload
+
+// WebhookNotifier sends signed threshold webhooks, see NewWebhookNotifier
+type WebhookNotifier = webhook.Notifier
+
+// NewWebhookNotifier returns an observer that posts to url when tokens cross
+// the thresholds, signed with secret, retrying with exponential backoff. Each
+// threshold fires once per window across replicas sharing the storage.
+// Register it with Limiter.AddObserver and Close it on shutdown.
+func NewWebhookNotifier(storage Storage, url string, secret string, thresholds []WebhookThreshold) *WebhookNotifier {
+	return webhook.NewNotifier(storage, url, secret, thresholds)
+}
+
+// ParseWebhookThresholds parses a comma separated list of period:percent entries and "blocked"
+func ParseWebhookThresholds(value string) ([]WebhookThreshold, error) {
+	return webhook.ParseThresholds(value)
+}
+
+// SignWebhook returns the signature receivers compare with the
+// X-Webhook-Signature header (after "sha256=")
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	return webhook.Sign(secret, timestamp, body)
+}
+
+// ParseFailureMode converts a configuration value into a FailureMode
+func ParseFailureMode(value string) (FailureMode, error) {
+	return limiter.ParseFailureMode(value)
+}
+
+// Clock tells the current time, see WithClock
+type Clock = clock.Clock
+
+// FakeClock is a clock that only moves when told to, for tests
+type FakeClock = clock.Fake
+
+// NewFakeClock creates a fake clock stopped at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return clock.NewFake(now)
+}
+
+// Storage backends and decorators
+type (
+	RedisOptions   = strategy.RedisOptions
+	RedisStorage   = strategy.RedisStorage
+	MemoryStorage  = strategy.MemoryStorage
+	CircuitBreaker = strategy.CircuitBreakerStorage
+	CircuitState   = strategy.CircuitState
+	CachedStorage  = strategy.CachedStorage
+	FaultStorage   = strategy.FaultStorage
+	FaultConfig    = strategy.FaultConfig
+)
+
+// ErrInjectedFault is returned by calls that a FaultStorage made fail
+var ErrInjectedFault = strategy.ErrInjectedFault
+
+const (
+	CircuitClosed   = strategy.CircuitClosed
+	CircuitOpen     = strategy.CircuitOpen
+	CircuitHalfOpen = strategy.CircuitHalfOpen
+)
+
+// NewRedisStorage connects to Redis (standalone, sentinel or cluster)
+func NewRedisStorage(opts RedisOptions) (*RedisStorage, error) {
+	return strategy.NewRedisStorageWithOptions(opts)
+}
+
+// NewMemoryStorage creates an in-process storage, limits are enforced per instance
+func NewMemoryStorage() *MemoryStorage {
+	return strategy.NewMemoryStorage()
+}
+
+// NewMemoryStorageWithClock creates an in-process storage that expires keys
+// according to the clock, e.g. a FakeClock to test expiry without sleeping
+func NewMemoryStorageWithClock(clock Clock) *MemoryStorage {
+	return strategy.NewMemoryStorageWithClock(clock)
+}
+
+// NewCircuitBreaker wraps a storage and stops calling it after threshold consecutive failures
+func NewCircuitBreaker(storage Storage, threshold int, openTimeoutSeconds int) *CircuitBreaker {
+	return strategy.NewCircuitBreakerStorage(storage, threshold, openTimeoutSeconds)
+}
+
+// NewCachedStorage puts a local cache for blocked keys and batched increments in front of a storage
+func NewCachedStorage(storage Storage, batchSize int, maxStalenessMs int, maxKeys int) *CachedStorage {
+	return strategy.NewCachedStorage(storage, batchSize, maxStalenessMs, maxKeys)
+}
+
+// NewFaultStorage wraps a storage and injects latency, errors, timeouts and
+// partial failures, to test behavior under a degraded storage. Never use it
+// in production.
+func NewFaultStorage(storage Storage, config FaultConfig) *FaultStorage {
+	return strategy.NewFaultStorage(storage, config)
+}
+
+// New creates a limiter on top of the storage. Without options it allows 5
+// requests per window and blocks for 300 seconds, failing closed. Invalid
+// quotas are logged and left out; use NewE to get the error instead.
+func New(storage Storage, opts ...Option) *Limiter {
+	rl, err := build(storage, opts)
+	if err != nil {
+		log.Printf("ratelimit: %v", err)
+	}
+	return rl
+}
+
+// NewE is like New, but returns an error when an option is invalid, e.g. a
+// quota with an unknown period or a negative limit
+func NewE(storage Storage, opts ...Option) (*Limiter, error) {
+	rl, err := build(storage, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// build applies the options, leaving out the invalid ones and reporting them
+func build(storage Storage, opts []Option) (*Limiter, error) {
+	s := defaultSettings()
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	rl := limiter.NewRateLimiter(storage, s.limit, s.blockDuration)
+	rl.SetFailureMode(s.failureMode, s.fallback)
+	rl.SetDecisionTimeout(s.timeout)
+	for _, token := range s.tokens {
+		rl.ConfigureToken(token.token, token.limit, token.blockDuration)
+	}
+	for _, policy := range s.policies {
+		if policy.Shadow {
+			rl.ConfigureShadowPolicy(policy.Name, policy.Limit, policy.BlockDuration)
+			continue
+		}
+		rl.ConfigurePolicy(policy.Name, policy.Limit, policy.BlockDuration)
+	}
+	var errs []error
+	for _, quota := range s.quotas {
+		if err := rl.ConfigureQuotas(quota.token, quota.windows...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.quotaLocation != nil {
+		rl.SetQuotaLocation(s.quotaLocation)
+	}
+	if s.clock != nil {
+		rl.SetClock(s.clock)
+	}
+	return rl, errors.Join(errs...)
+}
+
+// Middleware returns the HTTP middleware that applies the limiter to every request
+func Middleware(rl *Limiter) func(http.Handler) http.Handler {
+	return middleware.RateLimiterMiddleware(rl)
+}
+
+// CostFunc returns how many units a request consumes
+type CostFunc = middleware.CostFunc
+
+// CostRule sets the cost of requests by path prefix and optional method
+type CostRule = middleware.CostRule
+
+// ParseCostRules parses a comma separated list of [METHOD ]/prefix=cost entries
+func ParseCostRules(value string) ([]CostRule, error) {
+	return middleware.ParseCostRules(value)
+}
+
+// RouteCost returns the cost of the longest matching rule, or of fallback (1 when nil)
+func RouteCost(rules []CostRule, fallback CostFunc) CostFunc {
+	return middleware.RouteCost(rules, fallback)
+}
+
+// HeaderCost reads the cost from a header set by trusted upstreams, or uses fallback (1 when nil)
+func HeaderCost(header string, fallback CostFunc) CostFunc {
+	return middleware.HeaderCost(header, fallback)
+}
+
+// MiddlewareWithCost is like Middleware but each request consumes the units returned by cost
+func MiddlewareWithCost(rl *Limiter, cost CostFunc) func(http.Handler) http.Handler {
+	return middleware.RateLimiterMiddlewareWithCost(rl, cost)
+}
+
+// ShadowRule compares a shadow policy with the enforced limits on a route prefix
+type ShadowRule = middleware.ShadowRule
+
+// ParseShadowRules parses a comma separated list of /prefix=policy entries
+func ParseShadowRules(value string) ([]ShadowRule, error) {
+	return middleware.ParseShadowRules(value)
+}
+
+// MiddlewareWithShadow is like MiddlewareWithCost and also checks the shadow
+// policy of the longest matching rule, counting where it disagrees with the
+// enforced decision. Shadow policies never deny.
+func MiddlewareWithShadow(rl *Limiter, cost CostFunc, rules []ShadowRule) func(http.Handler) http.Handler {
+	return middleware.RateLimiterMiddlewareWithShadow(rl, cost, rules)
+}
+
+// ShadowStatsHandler reports the counters of every shadow policy as JSON
+func ShadowStatsHandler(rl *Limiter) http.HandlerFunc {
+	return middleware.ShadowStatsHandler(rl)
+}
+
+// StorageFailuresHandler reports the storage timeouts and errors of the limiter as JSON
+func StorageFailuresHandler(rl *Limiter) http.HandlerFunc {
+	return middleware.StorageFailuresHandler(rl)
+}
+
+// ConcurrencyLimiter caps in-flight requests per client with leases in the storage
+type ConcurrencyLimiter = limiter.ConcurrencyLimiter
+
+// Slot is an acquired in-flight slot, released when the request completes
+type Slot = limiter.Slot
+
+// ConcurrencyRule limits in-flight requests per client on a route prefix
+type ConcurrencyRule = middleware.ConcurrencyRule
+
+// NewConcurrencyLimiter creates a limiter of in-flight requests per client.
+// The storage must support leases (Redis and memory do); leases not renewed
+// within lease expire, so crashed instances do not hold slots. FailLocal
+// falls back to an in-memory storage. It returns an error for a storage
+// without leases or a lease under a millisecond.
+func NewConcurrencyLimiter(storage Storage, defaultLimit int, lease time.Duration, mode FailureMode) (*ConcurrencyLimiter, error) {
+	cl, err := limiter.NewConcurrencyLimiter(storage, defaultLimit, lease)
+	if err != nil {
+		return nil, err
+	}
+	var fallback Storage
+	if mode == FailLocal {
+		fallback = NewMemoryStorage()
+	}
+	cl.SetFailureMode(mode, fallback)
+	return cl, nil
+}
+
+// ParseConcurrencyRules parses a comma separated list of /prefix=limit entries
+func ParseConcurrencyRules(value string) ([]ConcurrencyRule, error) {
+	return middleware.ParseConcurrencyRules(value)
+}
+
+// ParseConcurrencyTokens parses a comma separated list of token=limit entries
+func ParseConcurrencyTokens(value string) (map[string]int, error) {
+	return limiter.ParseConcurrencyTokens(value)
+}
+
+// ConcurrencyMiddleware caps in-flight requests per client, releasing the
+// slot when the handler returns or panics
+func ConcurrencyMiddleware(cl *ConcurrencyLimiter, rules []ConcurrencyRule) func(http.Handler) http.Handler {
+	return middleware.ConcurrencyMiddleware(cl, rules)
+}
+
+// ForwardAuthHandler returns the endpoint for nginx auth_request and
+// Traefik/Caddy forward-auth, denied requests get denyStatus
+func ForwardAuthHandler(rl *Limiter, denyStatus int) http.HandlerFunc {
+	return middleware.ForwardAuthHandler(rl, denyStatus)
+}
+
+// KeyFunc returns the key outbound requests are counted under
+type KeyFunc = transport.KeyFunc
+
+// HostKey counts outbound requests per destination host
+func HostKey(r *http.Request) string {
+	return transport.HostKey(r)
+}
+
+// NewTransport wraps next (http.DefaultTransport when nil) so outbound requests
+// wait until the policy allows them. Upstream Retry-After and X-RateLimit-*
+// responses block the key in the storage, shared by every replica. It returns
+// ErrUnknownPolicy when the policy is not configured in rl.
+func NewTransport(next http.RoundTripper, rl *Limiter, policy string, key KeyFunc) (http.RoundTripper, error) {
+	t, err := transport.New(next, rl, policy, key)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,231 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit"
+)
+
+// Compile-time guards for the exported surface. Changing any of these
+// signatures breaks importers and must be a deliberate, versioned change.
+var (
+	_ func(ratelimit.Storage, ...ratelimit.Option) *ratelimit.Limiter                                            = ratelimit.New
+	_ func(ratelimit.Storage, ...ratelimit.Option) (*ratelimit.Limiter, error)                                   = ratelimit.NewE
+	_ func(*ratelimit.Limiter) func(http.Handler) http.Handler                                                   = ratelimit.Middleware
+	_ func(ratelimit.RedisOptions) (*ratelimit.RedisStorage, error)                                              = ratelimit.NewRedisStorage
+	_ func() *ratelimit.MemoryStorage                                                                            = ratelimit.NewMemoryStorage
+	_ func(ratelimit.Storage, int, int) *ratelimit.CircuitBreaker                                                = ratelimit.NewCircuitBreaker
+	_ func(ratelimit.Storage, int, int, int) *ratelimit.CachedStorage                                            = ratelimit.NewCachedStorage
+	_ func(string) (ratelimit.FailureMode, error)                                                                = ratelimit.ParseFailureMode
+	_ func(int) ratelimit.Option                                                                                 = ratelimit.WithLimit
+	_ func(int) ratelimit.Option                                                                                 = ratelimit.WithBlockDuration
+	_ func(string, int, int) ratelimit.Option                                                                    = ratelimit.WithToken
+	_ func(ratelimit.FailureMode) ratelimit.Option                                                               = ratelimit.WithFailureMode
+	_ func(ratelimit.Storage) ratelimit.Option                                                                   = ratelimit.WithFallbackStorage
+	_ func(*ratelimit.Limiter, context.Context, string, string) (bool, error)                                    = (*ratelimit.Limiter).Allow
+	_ func(*ratelimit.Limiter, context.Context, string, string) error                                            = (*ratelimit.Limiter).Reset
+	_ func(*ratelimit.Limiter, string, int, int)                                                                 = (*ratelimit.Limiter).ConfigureToken
+	_ func(*ratelimit.Limiter) error                                                                             = (*ratelimit.Limiter).Close
+	_ func(*ratelimit.CircuitBreaker) ratelimit.CircuitState                                                     = (*ratelimit.CircuitBreaker).State
+	_ func(ratelimit.RedisOptions) error                                                                         = ratelimit.RedisOptions.Validate
+	_ func(*ratelimit.CachedStorage, context.Context) error                                                      = (*ratelimit.CachedStorage).Flush
+	_ func(*ratelimit.Limiter, ratelimit.FailureMode, ratelimit.Storage)                                         = (*ratelimit.Limiter).SetFailureMode
+	_ func(*ratelimit.Limiter, context.Context, string, []string, int) (ratelimit.Decision, error)               = (*ratelimit.Limiter).Check
+	_ func(*ratelimit.Limiter, context.Context, string, string) (ratelimit.Decision, error)                      = (*ratelimit.Limiter).Decide
+	_ func(string, int, int) ratelimit.Option                                                                    = ratelimit.WithPolicy
+	_ func(string) ([]ratelimit.Policy, error)                                                                   = ratelimit.ParsePolicies
+	_ func(*ratelimit.Limiter, int) http.HandlerFunc                                                             = ratelimit.ForwardAuthHandler
+	_ func(*ratelimit.Limiter, context.Context, string, []string, time.Duration) error                           = (*ratelimit.Limiter).Block
+	_ func(http.RoundTripper, *ratelimit.Limiter, string, ratelimit.KeyFunc) (http.RoundTripper, error)          = ratelimit.NewTransport
+	_ ratelimit.KeyFunc                                                                                          = ratelimit.HostKey
+	_ func(*ratelimit.Limiter, context.Context, string, []string) error                                          = (*ratelimit.Limiter).Wait
+	_ func(*ratelimit.Limiter, context.Context, string, []string, int) (*ratelimit.Reservation, error)           = (*ratelimit.Limiter).Reserve
+	_ func(*ratelimit.Reservation) time.Duration                                                                 = (*ratelimit.Reservation).Delay
+	_ func(*ratelimit.Reservation, context.Context) error                                                        = (*ratelimit.Reservation).Wait
+	_ func(*ratelimit.Reservation, context.Context) error                                                        = (*ratelimit.Reservation).Cancel
+	_ error                                                                                                      = ratelimit.ErrExceedsLimit
+	_ error                                                                                                      = ratelimit.ErrReservationCanceled
+	_ func(*ratelimit.Limiter, context.Context, string, string, int) (bool, error)                               = (*ratelimit.Limiter).AllowN
+	_ func(*ratelimit.Limiter, ratelimit.CostFunc) func(http.Handler) http.Handler                               = ratelimit.MiddlewareWithCost
+	_ func(string) ([]ratelimit.CostRule, error)                                                                 = ratelimit.ParseCostRules
+	_ func([]ratelimit.CostRule, ratelimit.CostFunc) ratelimit.CostFunc                                          = ratelimit.RouteCost
+	_ func(string, ratelimit.CostFunc) ratelimit.CostFunc                                                        = ratelimit.HeaderCost
+	_ func(ratelimit.Storage, int, time.Duration, ratelimit.FailureMode) (*ratelimit.ConcurrencyLimiter, error)  = ratelimit.NewConcurrencyLimiter
+	_ func(*ratelimit.ConcurrencyLimiter, string, int)                                                           = (*ratelimit.ConcurrencyLimiter).ConfigureToken
+	_ func(*ratelimit.ConcurrencyLimiter, context.Context, string, string, string, int) (*ratelimit.Slot, error) = (*ratelimit.ConcurrencyLimiter).Acquire
+	_ func(*ratelimit.Slot, context.Context) error                                                               = (*ratelimit.Slot).Release
+	_ func(string) ([]ratelimit.ConcurrencyRule, error)                                                          = ratelimit.ParseConcurrencyRules
+	_ func(string) (map[string]int, error)                                                                       = ratelimit.ParseConcurrencyTokens
+	_ func(*ratelimit.ConcurrencyLimiter, []ratelimit.ConcurrencyRule) func(http.Handler) http.Handler           = ratelimit.ConcurrencyMiddleware
+	_ func(string, ...ratelimit.QuotaWindow) ratelimit.Option                                                    = ratelimit.WithQuotas
+	_ func(*time.Location) ratelimit.Option                                                                      = ratelimit.WithQuotaLocation
+	_ func(string) (map[string][]ratelimit.QuotaWindow, error)                                                   = ratelimit.ParseQuotas
+	_ func(*ratelimit.Limiter, string, ...ratelimit.QuotaWindow) error                                           = (*ratelimit.Limiter).ConfigureQuotas
+	_ func(*ratelimit.Limiter, *time.Location)                                                                   = (*ratelimit.Limiter).SetQuotaLocation
+	_ func(*ratelimit.Limiter, context.Context, string) ([]ratelimit.QuotaUsage, error)                          = (*ratelimit.Limiter).QuotaUsage
+	_ func(*ratelimit.Limiter, ratelimit.Observer)                                                               = (*ratelimit.Limiter).AddObserver
+	_ func(ratelimit.Storage, time.Duration, time.Duration) (*ratelimit.UsageRecorder, error)                    = ratelimit.NewUsageRecorder
+	_ ratelimit.Observer                                                                                         = (*ratelimit.UsageRecorder)(nil)
+	_ func(*ratelimit.UsageRecorder, context.Context) error                                                      = (*ratelimit.UsageRecorder).Flush
+	_ func(*ratelimit.UsageRecorder, context.Context) error                                                      = (*ratelimit.UsageRecorder).Close
+	_ func(ratelimit.Storage, string, string, []ratelimit.WebhookThreshold) *ratelimit.WebhookNotifier           = ratelimit.NewWebhookNotifier
+	_ func(string) ([]ratelimit.WebhookThreshold, error)                                                         = ratelimit.ParseWebhookThresholds
+	_ func([]byte, string, []byte) string                                                                        = ratelimit.SignWebhook
+	_ ratelimit.Observer                                                                                         = (*ratelimit.WebhookNotifier)(nil)
+	_ func(*ratelimit.WebhookNotifier, context.Context) error                                                    = (*ratelimit.WebhookNotifier).Close
+	_ func(string, int, int) ratelimit.Option                                                                    = ratelimit.WithShadowPolicy
+	_ func(*ratelimit.Limiter, string, int, int)                                                                 = (*ratelimit.Limiter).ConfigureShadowPolicy
+	_ func(*ratelimit.Limiter) map[string]ratelimit.ShadowStats                                                  = (*ratelimit.Limiter).ShadowStats
+	_ func(*ratelimit.Limiter, context.Context, string, []string, int, bool) (ratelimit.Decision, error)         = (*ratelimit.Limiter).CompareShadow
+	_ func(string) ([]ratelimit.ShadowRule, error)                                                               = ratelimit.ParseShadowRules
+	_ func(*ratelimit.Limiter, ratelimit.CostFunc, []ratelimit.ShadowRule) func(http.Handler) http.Handler       = ratelimit.MiddlewareWithShadow
+	_ func(*ratelimit.Limiter) http.HandlerFunc                                                                  = ratelimit.ShadowStatsHandler
+	_ func(time.Duration) ratelimit.Option                                                                       = ratelimit.WithDecisionTimeout
+	_ func(*ratelimit.Limiter, time.Duration)                                                                    = (*ratelimit.Limiter).SetDecisionTimeout
+	_ func(*ratelimit.Limiter) ratelimit.StorageFailures                                                         = (*ratelimit.Limiter).StorageFailures
+	_ func(*ratelimit.Limiter) http.HandlerFunc                                                                  = ratelimit.StorageFailuresHandler
+	_ error                                                                                                      = ratelimit.ErrStorageTimeout
+	_ func(*ratelimit.ConcurrencyLimiter) error                                                                  = (*ratelimit.ConcurrencyLimiter).Close
+	_ func(ratelimit.Clock) ratelimit.Option                                                                     = ratelimit.WithClock
+	_ func(*ratelimit.Limiter, ratelimit.Clock)                                                                  = (*ratelimit.Limiter).SetClock
+	_ func(ratelimit.Clock) *ratelimit.MemoryStorage                                                             = ratelimit.NewMemoryStorageWithClock
+	_ func(time.Time) *ratelimit.FakeClock                                                                       = ratelimit.NewFakeClock
+	_ ratelimit.Clock                                                                                            = (*ratelimit.FakeClock)(nil)
+	_ func(*ratelimit.FakeClock, time.Duration)                                                                  = (*ratelimit.FakeClock).Advance
+	_ error                                                                                                      = ratelimit.ErrStorageUnavailable
+	_ error                                                                                                      = ratelimit.ErrUnknownPolicy
+	_ ratelimit.FailureMode                                                                                      = ratelimit.FailClosed
+	_ ratelimit.FailureMode                                                                                      = ratelimit.FailOpen
+	_ ratelimit.FailureMode                                                                                      = ratelimit.FailLocal
+	_ ratelimit.CircuitState                                                                                     = ratelimit.CircuitClosed
+	_ ratelimit.CircuitState                                                                                     = ratelimit.CircuitOpen
+	_ ratelimit.CircuitState                                                                                     = ratelimit.CircuitHalfOpen
+	_ ratelimit.Storage                                                                                          = (*ratelimit.MemoryStorage)(nil)
+	_ ratelimit.Storage                                                                                          = (*ratelimit.RedisStorage)(nil)
+	_ ratelimit.Storage                                                                                          = (*ratelimit.CircuitBreaker)(nil)
+	_ func(ratelimit.Storage, ratelimit.FaultConfig) *ratelimit.FaultStorage                                     = ratelimit.NewFaultStorage
+	_ func(*ratelimit.FaultStorage, ratelimit.FaultConfig)                                                       = (*ratelimit.FaultStorage).SetConfig
+	_ func(ratelimit.FaultConfig) error                                                                          = ratelimit.FaultConfig.Validate
+	_ ratelimit.Storage                                                                                          = (*ratelimit.FaultStorage)(nil)
+	_ error                                                                                                      = ratelimit.ErrInjectedFault
+	_ ratelimit.Storage                                                                                          = (*ratelimit.CachedStorage)(nil)
+)
+
+// TestNewAppliesOptions tests that functional options configure the limiter
+func TestNewAppliesOptions(t *testing.T) {
+	rl := ratelimit.New(
+		ratelimit.NewMemoryStorage(),
+		ratelimit.WithLimit(2),
+		ratelimit.WithBlockDuration(60),
+		ratelimit.WithToken("premium", 4, 60),
+	)
+	defer rl.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := rl.Allow(ctx, "10.0.0.1", ""); !allowed {
+			t.Fatalf("IP request %d should be allowed", i+1)
+		}
+	}
+	if allowed, _ := rl.Allow(ctx, "10.0.0.1", ""); allowed {
+		t.Fatal("3rd IP request should be denied")
+	}
+
+	for i := 0; i < 4; i++ {
+		if allowed, _ := rl.Allow(ctx, "10.0.0.1", "premium"); !allowed {
+			t.Fatalf("Token request %d should be allowed", i+1)
+		}
+	}
+}
+
+// TestInvalidQuotasDoNotPanic tests that invalid quota options are reported by
+// NewE and left out by New
+func TestInvalidQuotasDoNotPanic(t *testing.T) {
+	invalid := ratelimit.WithQuotas("plan", ratelimit.QuotaWindow{Period: "week", Limit: 10})
+
+	if _, err := ratelimit.NewE(ratelimit.NewMemoryStorage(), invalid); err == nil {
+		t.Fatal("Expected an error for an unknown quota period")
+	}
+
+	rl := ratelimit.New(ratelimit.NewMemoryStorage(), ratelimit.WithLimit(1), invalid)
+	defer rl.Close()
+	if usage, err := rl.QuotaUsage(context.Background(), "plan"); err != nil || usage != nil {
+		t.Fatalf("Invalid quotas should be left out, got %+v, %v", usage, err)
+	}
+
+	valid, err := ratelimit.NewE(ratelimit.NewMemoryStorage(), ratelimit.WithQuotas("plan", ratelimit.QuotaWindow{Period: ratelimit.PeriodMinute, Limit: 10}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	valid.Close()
+}
+
+// downStorage is a Storage implemented outside the package that always fails
+type downStorage struct {
+	*ratelimit.MemoryStorage
+}
+
+func (d downStorage) GetCounter(ctx context.Context, key string) (int, error) {
+	return 0, errors.New("storage down")
+}
+
+// TestFailLocalUsesMemoryFallback tests that FailLocal works without an explicit fallback
+func TestFailLocalUsesMemoryFallback(t *testing.T) {
+	rl := ratelimit.New(
+		downStorage{ratelimit.NewMemoryStorage()},
+		ratelimit.WithLimit(1),
+		ratelimit.WithFailureMode(ratelimit.FailLocal),
+	)
+	defer rl.Close()
+
+	ctx := context.Background()
+
+	if allowed, err := rl.Allow(ctx, "10.0.0.1", ""); err != nil || !allowed {
+		t.Fatalf("Expected request to be allowed, got %v (%v)", allowed, err)
+	}
+	if allowed, _ := rl.Allow(ctx, "10.0.0.1", ""); allowed {
+		t.Fatal("2nd request should be denied by the local fallback")
+	}
+}
+
+// TestMiddleware tests the exported HTTP middleware
+func TestMiddleware(t *testing.T) {
+	rl := ratelimit.New(ratelimit.NewMemoryStorage(), ratelimit.WithLimit(1))
+	defer rl.Close()
+
+	handler := ratelimit.Middleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, expected := range codes {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != expected {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, expected, w.Code)
+		}
+	}
+}
+
+// TestCheckPolicy tests checks against a named policy with cost
+func TestCheckPolicy(t *testing.T) {
+	rl := ratelimit.New(ratelimit.NewMemoryStorage(), ratelimit.WithPolicy("export", 10, 60))
+	defer rl.Close()
+
+	decision, err := rl.Check(context.Background(), "export", []string{"tenant:acme"}, 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !decision.Allowed || decision.Remaining != 6 || decision.Limit != 10 {
+		t.Fatalf("Unexpected decision %+v", decision)
+	}
+}
@@ -0,0 +1,20 @@
+// Package storagetest checks custom ratelimit.Storage implementations
+// against the behavior of the built-in backends.
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/internal/strategy/strategytest"
+)
+
+// Harness is a storage under test. Advance moves the storage time forward so
+// keys expire; when nil the suite sleeps instead.
+type Harness = strategytest.Harness
+
+// Run checks increments, expiry, delete, exists, concurrency, context
+// cancellation and Close, plus the optional interfaces the storage
+// implements. newHarness is called for each test.
+func Run(t *testing.T, newHarness func(t *testing.T) Harness) {
+	strategytest.Run(t, newHarness)
+}
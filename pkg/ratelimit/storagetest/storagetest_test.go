@@ -0,0 +1,17 @@
+package storagetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit"
+	"github.com/SaraPMC/GO-desafio-rate-limiter/pkg/ratelimit/storagetest"
+)
+
+// TestMemoryStorage runs the suite the way a custom storage would
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Harness {
+		clock := ratelimit.NewFakeClock(time.Now())
+		return storagetest.Harness{Storage: ratelimit.NewMemoryStorageWithClock(clock), Advance: clock.Advance}
+	})
+}